@@ -2,6 +2,7 @@ package concurrent
 
 import (
 	"math/rand"
+	"runtime"
 	"sync"
 	"time"
 )
@@ -18,24 +19,23 @@ import (
 // difference in the sizes of the queues must be greater than or equal to
 // thresholdBalance. You must use this parameter in your implementation.
 func NewWorkBalancingExecutor(capacity, thresholdQueue, thresholdBalance int) ExecutorService {
-	globalQueue := NewUnBoundedDEQueue()
-	localQueueList := make([]DEQueue, capacity)
-	execService := &ExecService{
-		capacity:       capacity,
-		threshold:      thresholdQueue,
-		globalQueue:    globalQueue,
-		localQueueList: localQueueList,
-		done:           false,
-		wg:             &sync.WaitGroup{},
-	}
+	return NewWorkBalancingExecutorWithOptions(capacity, thresholdQueue, thresholdBalance, ExecutorOptions{})
+}
+
+// NewWorkBalancingExecutorWithOptions returns the executor of NewWorkBalancingExecutor with optional features
+func NewWorkBalancingExecutorWithOptions(capacity, thresholdQueue, thresholdBalance int, options ExecutorOptions) ExecutorService {
+	execService := newExecService(thresholdQueue, options)
 
 	worker := func(execService *ExecService, workerId int, wg *sync.WaitGroup) {
 		defer wg.Done()
 		for {
+			// PARK WHILE THE EXECUTOR IS RESIZED BELOW THIS WORKER
+			execService.park(workerId)
+			localQueueList := execService.localQueues()
 			// BREAKING CONDITION
-			if execService.done &&
+			if execService.isDone() &&
 				execService.globalQueue.IsEmpty() &&
-				execService.localQueueList[workerId].IsEmpty() {
+				localQueueList[workerId].IsEmpty() {
 				break
 			}
 
@@ -45,7 +45,7 @@ func NewWorkBalancingExecutor(capacity, thresholdQueue, thresholdBalance int) Ex
 				if f == nil {
 					break
 				}
-				execService.localQueueList[workerId].PushBottom(f)
+				localQueueList[workerId].PushBottom(f)
 			}
 
 			// LOAD BALANCING ALGORITHM
@@ -61,17 +61,19 @@ func NewWorkBalancingExecutor(capacity, thresholdQueue, thresholdBalance int) Ex
 					}
 				}
 
-				sizeLocal := execService.localQueueList[workerId].Size()
-				if random(sizeLocal+1, -1) == sizeLocal {
-					victim := random(execService.capacity, workerId)
-					sizeVictim := execService.localQueueList[victim].Size()
+				// A SINGLE WORKER HAS NO VICTIM
+				workers := execService.activeWorkers(localQueueList)
+				sizeLocal := localQueueList[workerId].Size()
+				if workers > 1 && random(sizeLocal+1, -1) == sizeLocal {
+					victim := random(workers, workerId)
+					sizeVictim := localQueueList[victim].Size()
 
-					minQ := execService.localQueueList[victim]
-					maxQ := execService.localQueueList[workerId]
+					minQ := localQueueList[victim]
+					maxQ := localQueueList[workerId]
 
 					if sizeLocal < sizeVictim {
-						minQ = execService.localQueueList[workerId]
-						maxQ = execService.localQueueList[victim]
+						minQ = localQueueList[workerId]
+						maxQ = localQueueList[victim]
 					}
 
 					if maxQ.Size()-minQ.Size() > thresholdBalance {
@@ -86,50 +88,27 @@ func NewWorkBalancingExecutor(capacity, thresholdQueue, thresholdBalance int) Ex
 			// PERFORM LOAD BALANCING
 			loadBalancer()
 
+			// AN IDLE WORKER YIELDS, E.G. TO A PRODUCER BLOCKED ON FULL QUEUES
+			if localQueueList[workerId].IsEmpty() {
+				runtime.Gosched()
+			}
+
 			// WORK ON TASKS IN THE LOCAL QUEUE
-			for !execService.localQueueList[workerId].IsEmpty() {
-				f_ := execService.localQueueList[workerId].PopBottom()
+			for !localQueueList[workerId].IsEmpty() {
+				f_ := localQueueList[workerId].PopBottom()
 				if f_ == nil {
 					break
 				}
 
-				f := f_.(*future)
-				if task, ok := f.Task.(interface{ Call() interface{} }); ok {
-					f.Promise <- task.Call()
-				} else {
-					task := f.Task.(interface{ Run() })
-					task.Run()
-					f.Promise <- nil
-				}
-				close(f.Promise)
-
+				execService.execute(workerId, f_)
 			}
 
 		}
 	}
 
-	execService.wg.Add(1)
-	{
-		go func(e *ExecService) {
-			var wg sync.WaitGroup
-			wg.Add(capacity)
-
-			for i := 0; i < capacity; i++ {
-				localQueueList[i] = NewUnBoundedDEQueue()
-			}
-
-			// SPAWN WORKERS
-			for i := 0; i < capacity; i++ {
-				go worker(e, i, &wg)
-			}
-
-			// WAIT FOR EVERYONE TO FINISH WORKING
-			wg.Wait()
-
-			// NOTIFY SHUTDOWN ALL TASKS ARE COMPLETED
-			execService.wg.Done()
-		}(execService)
-	}
+	// SPAWN WORKERS
+	execService.worker = worker
+	execService.Resize(capacity)
 
 	return execService
 }
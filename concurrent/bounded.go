@@ -0,0 +1,65 @@
+package concurrent
+
+import (
+	"sync"
+)
+
+// places of at most capacity tasks, taken when a task is queued and freed
+// when it leaves the queues
+type taskBound struct {
+	capacity int
+	size     int // Places taken
+	peak     int // Most places taken at once
+	waiting  int // Blocked acquires
+	lock     *sync.Mutex
+	notFull  *sync.Cond
+}
+
+// return the places of at most capacity tasks, at least one
+func newTaskBound(capacity int) *taskBound {
+	if capacity < 1 {
+		capacity = 1
+	}
+	lock := &sync.Mutex{}
+	return &taskBound{capacity: capacity, lock: lock, notFull: sync.NewCond(lock)}
+}
+
+// take the place of a task, waiting while every place is taken if block is
+// set, and return whether it was taken
+func (b *taskBound) acquire(block bool) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for b.size >= b.capacity {
+		if !block {
+			return false
+		}
+		b.waiting++
+		b.notFull.Wait()
+		b.waiting--
+	}
+	b.size++
+	if b.size > b.peak {
+		b.peak = b.size
+	}
+	return true
+}
+
+// free the place of a task and wake the blocked acquires while at least
+// half of the places are free
+func (b *taskBound) release() {
+	b.lock.Lock()
+	b.size--
+	// WAKING A PRODUCER FOR EVERY TASK WOULD SWITCH GOROUTINES FOR EVERY TASK, EVERY RELEASE
+	// BELOW HALF WAKES THEM SO A PRODUCER THAT BLOCKS LATE IS NOT MISSED
+	if b.waiting > 0 && b.size <= b.capacity/2 {
+		b.notFull.Broadcast()
+	}
+	b.lock.Unlock()
+}
+
+// return the most places taken at once
+func (b *taskBound) maxSize() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.peak
+}
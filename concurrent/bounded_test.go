@@ -0,0 +1,168 @@
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// runnable task of the locality key 0
+type keyedTask struct {
+	runnableFunc
+}
+
+func (keyedTask) Locality() int {
+	return 0
+}
+
+// executors of both kinds with the options
+var boundedExecutors = []struct {
+	name string
+	new  func(workers int, options ExecutorOptions) *ExecService
+}{
+	{"ws", func(workers int, options ExecutorOptions) *ExecService {
+		return NewWorkStealingExecutorWithOptions(workers, 16, options).(*ExecService)
+	}},
+	{"wb", func(workers int, options ExecutorOptions) *ExecService {
+		return NewWorkBalancingExecutorWithOptions(workers, 16, 4, options).(*ExecService)
+	}},
+}
+
+// submit a burst of empty tasks without keeping their futures. The workers
+// are held until the queues refuse a task or every task is queued, as if
+// the producer were much faster than them.
+func burst(e *ExecService, workers, tasks int) {
+	gate := make(chan struct{})
+	var once sync.Once
+	open := func() {
+		once.Do(func() { close(gate) })
+	}
+	for w := 0; w < workers; w++ {
+		e.Submit(runnableFunc(func() { <-gate }))
+	}
+	var wg sync.WaitGroup
+	wg.Add(tasks)
+	task := keyedTask{wg.Done}
+	for i := 0; i < tasks; i++ {
+		if _, ok := e.TrySubmit(task); !ok {
+			open()
+			e.Submit(task)
+		}
+	}
+	open()
+	wg.Wait()
+}
+
+// TestBoundedBurst submits a hundred thousand tasks faster than they run
+// and fails unless the queues filled up to their capacity and never held
+// more, with and without tasks queued on the workers by locality
+func TestBoundedBurst(t *testing.T) {
+	const workers, tasks, capacity = 4, 100_000, 1024
+	for _, executor := range boundedExecutors {
+		for _, locality := range []bool{false, true} {
+			name := executor.name
+			if locality {
+				name += " locality"
+			}
+			t.Run(name, func(t *testing.T) {
+				e := executor.new(workers, ExecutorOptions{Locality: locality, QueueCapacity: capacity})
+				defer e.Shutdown()
+				burst(e, workers, tasks)
+				if peak := e.bound.maxSize(); peak != capacity {
+					t.Fatalf("at most %d tasks queued at once, want the capacity %d", peak, capacity)
+				}
+			})
+		}
+	}
+}
+
+// TestBoundedProducers runs producers blocked on a queue of one task on
+// many workers and fails unless every task runs, so no producer misses
+// the release of the place it waits for
+func TestBoundedProducers(t *testing.T) {
+	const workers, producers, tasks = 8, 3, 2000
+	for _, executor := range boundedExecutors {
+		t.Run(executor.name, func(t *testing.T) {
+			e := executor.new(workers, ExecutorOptions{QueueCapacity: 1})
+			defer e.Shutdown()
+			var ran int32
+			var wg sync.WaitGroup
+			wg.Add(producers * tasks)
+			for p := 0; p < producers; p++ {
+				go func() {
+					for i := 0; i < tasks; i++ {
+						e.Submit(runnableFunc(func() {
+							atomic.AddInt32(&ran, 1)
+							wg.Done()
+						}))
+					}
+				}()
+			}
+			if !waitTimeout(wg.Wait, 10*time.Second) {
+				t.Fatalf("%d of %d tasks ran, producers are still blocked", atomic.LoadInt32(&ran), producers*tasks)
+			}
+			if peak := e.bound.maxSize(); peak != 1 {
+				t.Fatalf("at most %d tasks queued at once, want 1", peak)
+			}
+		})
+	}
+}
+
+// TestBoundedQueueFull fills the queues of an executor of one worker and
+// fails unless TrySubmit refuses a task and Submit blocks until a task
+// starts, with and without tasks queued on the worker by locality
+func TestBoundedQueueFull(t *testing.T) {
+	for _, executor := range boundedExecutors {
+		for _, locality := range []bool{false, true} {
+			name := executor.name
+			if locality {
+				name += " locality"
+			}
+			t.Run(name, func(t *testing.T) {
+				e := executor.new(1, ExecutorOptions{Locality: locality, QueueCapacity: 2})
+				defer e.Shutdown()
+				noop := keyedTask{func() {}}
+				// THE WORKER RUNS THE KEY ONCE, SO WITH LOCALITY THE NEXT TASKS GO TO ITS QUEUE
+				e.Submit(noop).Get()
+
+				// A FAILED CHECK STILL RELEASES THE WORKER, OR SHUTDOWN WOULD WAIT FOR IT FOREVER
+				started, gate := make(chan struct{}), make(chan struct{})
+				var once sync.Once
+				release := func() {
+					once.Do(func() { close(gate) })
+				}
+				defer release()
+				e.Submit(keyedTask{func() {
+					close(started)
+					<-gate
+				}})
+				<-started
+				// A STARTED TASK HAS LEFT THE QUEUES, THESE TWO FILL THEM
+				e.Submit(noop)
+				e.Submit(noop)
+				if _, ok := e.TrySubmit(noop); ok {
+					t.Fatal("TrySubmit queued a task beyond the capacity")
+				}
+
+				submitted := make(chan struct{})
+				go func() {
+					e.Submit(noop)
+					close(submitted)
+				}()
+				select {
+				case <-submitted:
+					t.Fatal("Submit did not block on full queues")
+				case <-time.After(50 * time.Millisecond):
+				}
+
+				release()
+				select {
+				case <-submitted:
+				case <-time.After(5 * time.Second):
+					t.Fatal("Submit still blocked after the queued tasks ran")
+				}
+			})
+		}
+	}
+}
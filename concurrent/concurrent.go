@@ -1,6 +1,10 @@
 package concurrent
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 /**** YOU CANNOT MODIFY ANY OF THE FOLLOWING INTERFACES ********/
 
@@ -35,10 +39,21 @@ type ExecutorService interface {
 type future struct {
 	Task    interface{}
 	Promise chan interface{}
+	// Queueing hints, those of Task unless submitted with SubmitWith
+	priority, locality int
 }
 
 func NewFuture(task interface{}) *future {
-	return &future{Task: task, Promise: make(chan interface{}, 1)}
+	return &future{Task: task, Promise: make(chan interface{}, 1),
+		priority: taskPriority(task), locality: taskLocality(task)}
+}
+
+func (f *future) Priority() int {
+	return f.priority
+}
+
+func (f *future) Locality() int {
+	return f.locality
 }
 
 func (f *future) Get() interface{} {
@@ -46,21 +61,200 @@ func (f *future) Get() interface{} {
 }
 
 type ExecService struct {
-	capacity       int
+	capacity       int32 // Workers that are not parked, see Resize
 	threshold      int
 	globalQueue    DEQueue
-	localQueueList []DEQueue
-	done           bool
+	localQueueList atomic.Value // []DEQueue of every spawned worker, replaced when workers are spawned
+	done           int32        // 1 once Shutdown is called
 	wg             *sync.WaitGroup
+	tracking       int32 // 1 once TrackUtilization is called
+	busy           int64 // Nanoseconds spent running tasks, summed over workers
+	options        ExecutorOptions
+	// Places of the tasks queued and not started yet, nil if unbounded
+	bound *taskBound
+	// Worker that ran the last task of every locality key
+	affinity     map[int]int
+	affinityLock sync.Mutex
+	// Loop of a worker and the lock of Resize, read while a task is routed
+	// to a worker
+	worker     func(execService *ExecService, workerId int, wg *sync.WaitGroup)
+	resizeLock sync.RWMutex
+}
+
+// return an executor without workers, they are spawned by Resize
+func newExecService(threshold int, options ExecutorOptions) *ExecService {
+	execService := &ExecService{
+		threshold:   threshold,
+		globalQueue: options.newQueue(),
+		wg:          &sync.WaitGroup{},
+		options:     options,
+		affinity:    make(map[int]int),
+	}
+	execService.localQueueList.Store([]DEQueue{})
+	if options.QueueCapacity > 0 {
+		execService.bound = newTaskBound(options.QueueCapacity)
+	}
+	return execService
 }
 
 func (e *ExecService) Submit(task interface{}) Future {
 	f := NewFuture(task)
-	e.globalQueue.PushBottom(f)
+	e.push(f, true)
 	return f
 }
 
+// TrySubmit submits a task unless the queues are bounded and full, in which
+// case it returns false instead of blocking like Submit
+func (e *ExecService) TrySubmit(task interface{}) (Future, bool) {
+	f := NewFuture(task)
+	if !e.push(f, false) {
+		return nil, false
+	}
+	return f, true
+}
+
+// SubmitWith submits a task with a priority and a locality key (NoLocality
+// for none) overriding those of the task. Executors of other packages
+// ignore them.
+func SubmitWith(e ExecutorService, task interface{}, priority, locality int) Future {
+	service, ok := e.(*ExecService)
+	if !ok {
+		return e.Submit(task)
+	}
+	f := NewFuture(task)
+	f.priority, f.locality = priority, locality
+	service.push(f, true)
+	return f
+}
+
+// queue a task on the global queue or, with locality, on the local queue of
+// the worker that ran the last task of its key. If the queues are full it
+// blocks, or returns false without queueing the task if block is false.
+func (e *ExecService) push(task Task, block bool) bool {
+	// THE BOUND COUNTS EVERY QUEUE, TASKS ROUTED TO A WORKER WOULD ESCAPE A BOUND OF THE GLOBAL QUEUE
+	if e.bound != nil && !e.bound.acquire(block) {
+		return false
+	}
+	if e.options.Locality {
+		if key := taskLocality(task); key >= 0 {
+			e.affinityLock.Lock()
+			worker, ok := e.affinity[key]
+			e.affinityLock.Unlock()
+			// PARKED WORKERS GET NO NEW TASKS
+			if ok && e.pushLocal(worker, task) {
+				return true
+			}
+		}
+	}
+	e.globalQueue.PushBottom(task)
+	return true
+}
+
+// queue a task on the local queue of a worker unless it is parked, and
+// return whether it was queued
+func (e *ExecService) pushLocal(worker int, task Task) bool {
+	// A RESIZE WAITS UNTIL THE TASK IS QUEUED, SO A WORKER IT PARKS FINDS THE TASK
+	// WHEN IT RETURNS ITS QUEUE INSTEAD OF ONE POLL LATER
+	e.resizeLock.RLock()
+	defer e.resizeLock.RUnlock()
+	if worker >= e.Capacity() {
+		return false
+	}
+	e.localQueues()[worker].PushBottom(task)
+	return true
+}
+
+// queue a runnable that completes itself as it is on the executors of this
+// package, or submit it to any other executor
+func enqueue(e ExecutorService, task Runnable) {
+	if service, ok := e.(*ExecService); ok {
+		service.push(task, true)
+	} else {
+		e.Submit(task)
+	}
+}
+
+// queue a runnable like enqueue unless the queues of the executor are
+// bounded and full, and return whether it was queued
+func tryEnqueue(e ExecutorService, task Runnable) bool {
+	if service, ok := e.(*ExecService); ok {
+		return service.push(task, false)
+	}
+	e.Submit(task)
+	return true
+}
+
 func (e *ExecService) Shutdown() {
-	e.done = true
+	// NO WORKER IS SPAWNED ONCE SHUTDOWN WAITS FOR THEM
+	e.resizeLock.Lock()
+	atomic.StoreInt32(&e.done, 1)
+	e.resizeLock.Unlock()
 	e.wg.Wait()
 }
+
+// whether Shutdown was called
+func (e *ExecService) isDone() bool {
+	return atomic.LoadInt32(&e.done) == 1
+}
+
+// run a queued task on a worker: a future, whose promise is fulfilled, or
+// a task that completes itself
+func (e *ExecService) execute(workerId int, task Task) {
+	// THE TASK LEAVES THE QUEUES, ITS PLACE IS FREE FOR ANOTHER
+	if e.bound != nil {
+		e.bound.release()
+	}
+
+	var start time.Time
+	tracking := atomic.LoadInt32(&e.tracking) == 1
+	if tracking {
+		start = time.Now()
+	}
+
+	if f, ok := task.(*future); ok {
+		f.complete()
+	} else {
+		task.(Runnable).Run()
+	}
+
+	if tracking {
+		atomic.AddInt64(&e.busy, int64(time.Since(start)))
+	}
+
+	if e.options.Locality {
+		if key := taskLocality(task); key >= 0 {
+			e.affinityLock.Lock()
+			e.affinity[key] = workerId
+			e.affinityLock.Unlock()
+		}
+	}
+}
+
+// run the task of a future and fulfil its promise
+func (f *future) complete() {
+	if task, ok := f.Task.(interface{ Call() interface{} }); ok {
+		f.Promise <- task.Call()
+	} else {
+		task := f.Task.(interface{ Run() })
+		task.Run()
+		f.Promise <- nil
+	}
+	close(f.Promise)
+}
+
+// TrackUtilization starts measuring the time workers spend running tasks,
+// which costs two clock reads per task
+func (e *ExecService) TrackUtilization() {
+	atomic.StoreInt32(&e.tracking, 1)
+}
+
+// BusyTime returns the time spent running tasks since TrackUtilization
+// was called, summed over all workers
+func (e *ExecService) BusyTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&e.busy))
+}
+
+// Capacity returns the number of workers, which changes with Resize
+func (e *ExecService) Capacity() int {
+	return int(atomic.LoadInt32(&e.capacity))
+}
@@ -0,0 +1,177 @@
+package concurrent
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Elastic is implemented by executors whose number of workers changes at
+// runtime
+type Elastic interface {
+	ExecutorService
+	Resize(n int)
+	Capacity() int
+}
+
+// Time between the checks of a parked worker
+const parkInterval = time.Millisecond
+
+// Resize sets the number of workers to n, at least 1. Workers beyond n
+// park once their current task completes and return their local tasks to
+// the global queue, parked workers resume before new ones are spawned.
+// Resize is ignored during and after Shutdown.
+func (e *ExecService) Resize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	e.resizeLock.Lock()
+	defer e.resizeLock.Unlock()
+	if e.isDone() {
+		return
+	}
+
+	// THE QUEUES ARE STORED BEFORE THE CAPACITY, SO WORKERS NEVER SEE A VICTIM WITHOUT A QUEUE
+	queues := e.localQueues()
+	if n > len(queues) {
+		grown := make([]DEQueue, n)
+		copy(grown, queues)
+		for i := len(queues); i < n; i++ {
+			grown[i] = e.options.newQueue()
+		}
+		e.localQueueList.Store(grown)
+		e.wg.Add(n - len(queues))
+		for i := len(queues); i < n; i++ {
+			go e.worker(e, i, e.wg)
+		}
+	}
+	atomic.StoreInt32(&e.capacity, int32(n))
+}
+
+// return the local queues of every spawned worker, parked or not
+func (e *ExecService) localQueues() []DEQueue {
+	return e.localQueueList.Load().([]DEQueue)
+}
+
+// return the number of workers that are not parked, at most the number of
+// queues a worker loaded
+func (e *ExecService) activeWorkers(queues []DEQueue) int {
+	if workers := e.Capacity(); workers < len(queues) {
+		return workers
+	}
+	return len(queues)
+}
+
+// block a worker while the executor is resized below it
+func (e *ExecService) park(workerId int) {
+	for workerId >= e.Capacity() && !e.isDone() {
+		// TASKS STOLEN, BALANCED OR ROUTED HERE BEFORE THE RESIZE GO BACK TO THE GLOBAL QUEUE
+		queue := e.localQueues()[workerId]
+		for task := queue.PopTop(); task != nil; task = queue.PopTop() {
+			e.globalQueue.PushBottom(task)
+		}
+		time.Sleep(parkInterval)
+	}
+}
+
+// Autoscaler resizes an elastic executor before every phase of a
+// computation. It climbs the throughput of every phase on its own, one
+// worker at a time, between min and max workers and never above
+// GOMAXPROCS, so cores are handed back when the process gets fewer.
+type Autoscaler struct {
+	executor Elastic
+	min, max int
+	phases   map[string]*phaseScale
+	phase    *phaseScale // Phase since Begin
+	start    time.Time
+}
+
+// workers of the next run of a phase and the throughput of its last run
+type phaseScale struct {
+	workers    int
+	direction  int     // Change of workers that was tried last, +1 or -1
+	throughput float64 // Tasks per second of the last run
+}
+
+// return an autoscaler of executor between min and max workers, max <= 0
+// is GOMAXPROCS. Executors that are not Elastic are never resized.
+func NewAutoscaler(executor ExecutorService, min, max int) *Autoscaler {
+	elastic, _ := executor.(Elastic)
+	if min < 1 {
+		min = 1
+	}
+	return &Autoscaler{executor: elastic, min: min, max: max, phases: make(map[string]*phaseScale)}
+}
+
+// return the most workers the next phase may use
+func (a *Autoscaler) limit() int {
+	limit := runtime.GOMAXPROCS(0)
+	if a.max > 0 && a.max < limit {
+		limit = a.max
+	}
+	if limit < a.min {
+		limit = a.min
+	}
+	return limit
+}
+
+// Begin resizes the executor for a phase, the first run of a phase uses
+// the most workers allowed
+func (a *Autoscaler) Begin(phase string) {
+	if a.executor == nil {
+		return
+	}
+	s, ok := a.phases[phase]
+	if !ok {
+		s = &phaseScale{workers: a.limit(), direction: -1}
+		a.phases[phase] = s
+	}
+	if s.workers > a.limit() {
+		s.workers = a.limit()
+	}
+	if a.executor.Capacity() != s.workers {
+		a.executor.Resize(s.workers)
+	}
+	a.phase, a.start = s, time.Now()
+}
+
+// End records that the phase since Begin ran tasks tasks and chooses the
+// workers of its next run: one more or one less in the direction that
+// last improved the throughput
+func (a *Autoscaler) End(tasks int) {
+	s := a.phase
+	if s == nil {
+		return
+	}
+	a.phase = nil
+	elapsed := time.Since(a.start).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	throughput := float64(tasks) / elapsed
+	if s.throughput > 0 && throughput < s.throughput {
+		s.direction = -s.direction
+	}
+	s.throughput = throughput
+	s.workers += s.direction
+	if limit := a.limit(); s.workers > limit || s.workers < a.min {
+		// BOUNCE OFF THE BOUNDS
+		s.direction = -s.direction
+		s.workers += 2 * s.direction
+	}
+	if s.workers < a.min {
+		s.workers = a.min
+	} else if limit := a.limit(); s.workers > limit {
+		s.workers = limit
+	}
+}
+
+// Workers returns the workers of the next run of every phase
+func (a *Autoscaler) Workers() map[string]int {
+	workers := make(map[string]int, len(a.phases))
+	for name, s := range a.phases {
+		workers[name] = s.workers
+	}
+	return workers
+}
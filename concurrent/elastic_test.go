@@ -0,0 +1,207 @@
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// executors of both kinds whose workers take one task at a time from the
+// global queue, so tasks that block never wait behind each other
+var elasticExecutors = []struct {
+	name string
+	new  func(workers int, options ExecutorOptions) *ExecService
+}{
+	{"ws", func(workers int, options ExecutorOptions) *ExecService {
+		return NewWorkStealingExecutorWithOptions(workers, 1, options).(*ExecService)
+	}},
+	{"wb", func(workers int, options ExecutorOptions) *ExecService {
+		return NewWorkBalancingExecutorWithOptions(workers, 1, 2, options).(*ExecService)
+	}},
+}
+
+// block n workers of an executor until the returned function is called,
+// failing the test if they do not all start
+func holdWorkers(t *testing.T, e *ExecService, n int) func() {
+	var started sync.WaitGroup
+	started.Add(n)
+	gate := make(chan struct{})
+	var once sync.Once
+	release := func() {
+		once.Do(func() { close(gate) })
+	}
+	for i := 0; i < n; i++ {
+		e.Submit(runnableFunc(func() {
+			started.Done()
+			<-gate
+		}))
+	}
+	if !waitTimeout(started.Wait, 5*time.Second) {
+		release()
+		t.Fatalf("%d workers did not all start a task", n)
+	}
+	return release
+}
+
+// run wait and return whether it returned within timeout
+func waitTimeout(wait func(), timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// return a function waiting for every future
+func getAll(futures []Future) func() {
+	return func() {
+		for _, f := range futures {
+			f.Get()
+		}
+	}
+}
+
+// a parked worker hands the tasks of its queue to the global queue
+func TestParkReturnsTasks(t *testing.T) {
+	const tasks = 3
+	e := idleExecutor(2, ExecutorOptions{})
+	for i := 0; i < tasks; i++ {
+		e.localQueues()[1].PushBottom(NewFuture(runnableFunc(func() {})))
+	}
+	atomic.StoreInt32(&e.capacity, 1)
+	parked := make(chan struct{})
+	go func() {
+		e.park(1)
+		close(parked)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for e.globalQueue.Size() < tasks {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d tasks on the global queue", e.globalQueue.Size(), tasks)
+		}
+		time.Sleep(parkInterval)
+	}
+	if !e.localQueues()[1].IsEmpty() {
+		t.Fatal("the parked worker kept tasks")
+	}
+	select {
+	case <-parked:
+		t.Fatal("the worker left the park below the capacity")
+	default:
+	}
+	atomic.StoreInt32(&e.capacity, 2)
+	select {
+	case <-parked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the worker is still parked after a resize above it")
+	}
+}
+
+// tasks queued while the executor shrinks all run, with and without tasks
+// routed to the queues of the workers that park
+func TestResizeShrinkQueued(t *testing.T) {
+	const workers, tasks, keys = 4, 200, 8
+	for _, executor := range elasticExecutors {
+		for _, locality := range []bool{false, true} {
+			name := executor.name
+			if locality {
+				name += " locality"
+			}
+			t.Run(name, func(t *testing.T) {
+				e := executor.new(workers, ExecutorOptions{Locality: locality})
+				defer e.Shutdown()
+				noop := runnableFunc(func() {})
+				for key := 0; key < keys; key++ {
+					SubmitWith(e, noop, 0, key).Get()
+				}
+				release := holdWorkers(t, e, workers)
+				defer release()
+
+				var ran int32
+				futures := make([]Future, tasks)
+				for i := range futures {
+					futures[i] = SubmitWith(e, runnableFunc(func() {
+						atomic.AddInt32(&ran, 1)
+					}), 0, i%keys)
+				}
+				e.Resize(1)
+				release()
+				if !waitTimeout(getAll(futures), 5*time.Second) {
+					t.Fatalf("%d of %d tasks ran after shrinking", atomic.LoadInt32(&ran), tasks)
+				}
+				if e.Capacity() != 1 {
+					t.Fatalf("capacity %d, want 1", e.Capacity())
+				}
+
+				// THE KEYS OF PARKED WORKERS RUN ON THE ACTIVE ONE
+				for key := 0; key < keys; key++ {
+					futures[key] = SubmitWith(e, noop, 0, key)
+				}
+				if !waitTimeout(getAll(futures[:keys]), 5*time.Second) {
+					t.Fatal("tasks of the keys of parked workers did not run")
+				}
+			})
+		}
+	}
+}
+
+// workers parked by a shrink resume when the executor grows again, next to
+// newly spawned ones
+func TestResizeGrowAfterShrink(t *testing.T) {
+	for _, executor := range elasticExecutors {
+		t.Run(executor.name, func(t *testing.T) {
+			e := executor.new(4, ExecutorOptions{})
+			defer e.Shutdown()
+			e.Resize(1)
+			futures := make([]Future, 20)
+			for i := range futures {
+				futures[i] = e.Submit(runnableFunc(func() {}))
+			}
+			if !waitTimeout(getAll(futures), 5*time.Second) {
+				t.Fatal("tasks did not run on one worker")
+			}
+
+			e.Resize(6)
+			if e.Capacity() != 6 || len(e.localQueues()) != 6 {
+				t.Fatalf("capacity %d with %d queues, want 6", e.Capacity(), len(e.localQueues()))
+			}
+			// EVERY WORKER MUST RUN AT ONCE FOR ALL OF THEM TO START
+			holdWorkers(t, e, 6)()
+		})
+	}
+}
+
+// Shutdown returns once the queued tasks ran, even if workers are parked,
+// and a later Resize is ignored
+func TestShutdownParked(t *testing.T) {
+	for _, executor := range elasticExecutors {
+		t.Run(executor.name, func(t *testing.T) {
+			e := executor.new(4, ExecutorOptions{})
+			e.Resize(1)
+			// LET THE WORKERS REACH THE PARK
+			time.Sleep(5 * parkInterval)
+			var ran int32
+			for i := 0; i < 10; i++ {
+				e.Submit(runnableFunc(func() { atomic.AddInt32(&ran, 1) }))
+			}
+			if !waitTimeout(e.Shutdown, 5*time.Second) {
+				t.Fatal("Shutdown waits for parked workers")
+			}
+			if ran != 10 {
+				t.Fatalf("%d of 10 tasks ran before Shutdown returned", ran)
+			}
+			e.Resize(3)
+			if e.Capacity() != 1 {
+				t.Fatalf("capacity %d after a resize following Shutdown", e.Capacity())
+			}
+		})
+	}
+}
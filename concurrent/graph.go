@@ -0,0 +1,252 @@
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Graph is a set of tasks with dependencies. Run submits a task to the
+// executor as soon as every task it depends on has run, so independent
+// chains of tasks overlap instead of waiting for each other at barriers.
+type Graph struct {
+	nodes    []*GraphNode
+	edges    int
+	executor ExecutorService
+	inline   []*GraphNode // Ready tasks when Run has no executor
+	pending  sync.WaitGroup
+	start    time.Time
+	running  int32 // Tasks running now
+	peak     int32 // Most tasks running at once
+}
+
+// GraphNode is a task of a Graph, its Start and End are measured from the
+// start of the last Run
+type GraphNode struct {
+	Name       string
+	Start, End time.Duration
+	task       Runnable
+	graph      *Graph
+	deps       []*GraphNode
+	successors []*GraphNode
+	waiting    int32 // Dependencies that have not run yet in this Run
+	// Queueing hints, those of the task unless set with Hint
+	priority, locality int
+}
+
+// GraphStats are the statistics of one or more runs of graphs
+type GraphStats struct {
+	Runs, Tasks, Edges int
+	Depth              int           // Most tasks on a chain of dependencies
+	Peak               int           // Most tasks running at once
+	Wall               time.Duration // Time from the start of Run to the end of the last task
+	Work               time.Duration // Time spent running tasks, summed over tasks
+	CriticalPath       time.Duration // Longest time spent on a chain of dependencies
+	// Time spent running tasks, summed over the tasks of every name, joins
+	// are not counted
+	Phases map[string]time.Duration
+}
+
+// return an empty graph
+func NewGraph() *Graph {
+	return &Graph{}
+}
+
+// Add adds a task that runs after the tasks deps, which must have been
+// added to the same graph. Several tasks may share a name, the statistics
+// of a phase sum the tasks of its name.
+func (g *Graph) Add(name string, task Runnable, deps ...*GraphNode) *GraphNode {
+	node := &GraphNode{Name: name, task: task, graph: g, deps: deps,
+		priority: taskPriority(task), locality: taskLocality(task)}
+	for _, dep := range deps {
+		if dep.graph != g {
+			panic("Dependency of " + name + " belongs to another graph: " + dep.Name)
+		}
+		dep.successors = append(dep.successors, node)
+	}
+	g.nodes = append(g.nodes, node)
+	g.edges += len(deps)
+	return node
+}
+
+// AddFunc adds a function that runs after the tasks deps
+func (g *Graph) AddFunc(name string, fn func(), deps ...*GraphNode) *GraphNode {
+	return g.Add(name, runnableFunc(fn), deps...)
+}
+
+// Join adds an empty task that completes once the tasks deps have run.
+// n tasks after a join of m tasks need n + m edges instead of n * m.
+func (g *Graph) Join(name string, deps ...*GraphNode) *GraphNode {
+	return g.Add(name, nil, deps...)
+}
+
+// Run runs every task of the graph on the executor (or on the calling
+// goroutine if it is nil) in an order respecting the dependencies, waits
+// for all of them and returns the statistics of the run. Tasks are added
+// after their dependencies, so the graph cannot have cycles. The graph
+// can be run again once Run returns.
+func (g *Graph) Run(e ExecutorService) GraphStats {
+	g.executor, g.running, g.peak = e, 0, 0
+	for _, node := range g.nodes {
+		node.waiting = int32(len(node.deps))
+	}
+	g.pending.Add(len(g.nodes))
+	g.start = time.Now()
+
+	for _, node := range g.nodes {
+		if len(node.deps) == 0 {
+			g.ready(node)
+		}
+	}
+	// WITHOUT AN EXECUTOR THE READY TASKS RUN HERE, IN THE ORDER THEY BECAME READY
+	for len(g.inline) > 0 {
+		node := g.inline[0]
+		g.inline = g.inline[1:]
+		node.Run()
+	}
+	g.pending.Wait()
+	g.executor = nil
+	return g.stats()
+}
+
+// queue a task whose dependencies have run, empty tasks complete at once.
+// Tasks are queued by the workers that complete their dependencies, so if
+// the queues are full the worker runs the task itself instead of blocking
+// every worker on queues only they can empty.
+func (g *Graph) ready(node *GraphNode) {
+	if node.task == nil {
+		node.Start = time.Since(g.start)
+		node.End = node.Start
+		node.complete()
+	} else if g.executor == nil {
+		g.inline = append(g.inline, node)
+	} else if !tryEnqueue(g.executor, node) {
+		node.Run()
+	}
+}
+
+// Hint sets the priority and the locality key (NoLocality for none) the
+// node is queued with, and returns the node
+func (n *GraphNode) Hint(priority, locality int) *GraphNode {
+	n.priority, n.locality = priority, locality
+	return n
+}
+
+func (n *GraphNode) Priority() int {
+	return n.priority
+}
+
+func (n *GraphNode) Locality() int {
+	return n.locality
+}
+
+// Run runs the task of the node and queues the tasks that were waiting
+// for it last, a node is queued as a Runnable
+func (n *GraphNode) Run() {
+	g := n.graph
+	running := atomic.AddInt32(&g.running, 1)
+	for {
+		peak := atomic.LoadInt32(&g.peak)
+		if running <= peak || atomic.CompareAndSwapInt32(&g.peak, peak, running) {
+			break
+		}
+	}
+
+	n.Start = time.Since(g.start)
+	n.task.Run()
+	n.End = time.Since(g.start)
+
+	atomic.AddInt32(&g.running, -1)
+	n.complete()
+}
+
+// release the successors of a node that has run
+func (n *GraphNode) complete() {
+	for _, next := range n.successors {
+		if atomic.AddInt32(&next.waiting, -1) == 0 {
+			n.graph.ready(next)
+		}
+	}
+	n.graph.pending.Done()
+}
+
+// return the statistics of the last run, the nodes are in topological order
+func (g *Graph) stats() GraphStats {
+	stats := GraphStats{Runs: 1, Tasks: len(g.nodes), Edges: g.edges, Peak: int(g.peak),
+		Phases: make(map[string]time.Duration)}
+	depth := make(map[*GraphNode]int, len(g.nodes))
+	path := make(map[*GraphNode]time.Duration, len(g.nodes))
+	for _, node := range g.nodes {
+		duration := node.End - node.Start
+		stats.Work += duration
+		if node.task != nil {
+			stats.Phases[node.Name] += duration
+		}
+		if node.End > stats.Wall {
+			stats.Wall = node.End
+		}
+
+		for _, dep := range node.deps {
+			if depth[dep] > depth[node] {
+				depth[node] = depth[dep]
+			}
+			if path[dep] > path[node] {
+				path[node] = path[dep]
+			}
+		}
+		if node.task != nil {
+			depth[node]++
+		}
+		path[node] += duration
+		if depth[node] > stats.Depth {
+			stats.Depth = depth[node]
+		}
+		if path[node] > stats.CriticalPath {
+			stats.CriticalPath = path[node]
+		}
+	}
+	return stats
+}
+
+// Parallelism returns the work divided by the critical path, the speedup
+// the dependencies allow with unlimited workers
+func (s GraphStats) Parallelism() float64 {
+	if s.CriticalPath == 0 {
+		return 0
+	}
+	return float64(s.Work) / float64(s.CriticalPath)
+}
+
+// Add returns the statistics of the runs of both, the depth and the peak
+// are the largest of any run
+func (s GraphStats) Add(other GraphStats) GraphStats {
+	s.Runs += other.Runs
+	s.Tasks += other.Tasks
+	s.Edges += other.Edges
+	if other.Depth > s.Depth {
+		s.Depth = other.Depth
+	}
+	if other.Peak > s.Peak {
+		s.Peak = other.Peak
+	}
+	s.Wall += other.Wall
+	s.Work += other.Work
+	s.CriticalPath += other.CriticalPath
+
+	phases := make(map[string]time.Duration, len(s.Phases)+len(other.Phases))
+	for name, d := range s.Phases {
+		phases[name] += d
+	}
+	for name, d := range other.Phases {
+		phases[name] += d
+	}
+	s.Phases = phases
+	return s
+}
+
+// function adapting a func() to a Runnable
+type runnableFunc func()
+
+func (fn runnableFunc) Run() {
+	fn()
+}
@@ -0,0 +1,204 @@
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// return a graph of layers of width tasks, every task depending on two
+// tasks of the layer before, and the flags its tasks set once they ran.
+// A task fails the test if it runs before one of its dependencies.
+func layeredGraph(t *testing.T, layers, width int) (*Graph, []int32) {
+	g := NewGraph()
+	done := make([]int32, layers*width)
+	var last []*GraphNode
+	for l := 0; l < layers; l++ {
+		var layer []*GraphNode
+		for k := 0; k < width; k++ {
+			id := l*width + k
+			var deps []*GraphNode
+			var depIds []int
+			if l > 0 {
+				deps = []*GraphNode{last[k], last[(k+1)%width]}
+				depIds = []int{id - width, (l-1)*width + (k+1)%width}
+			}
+			layer = append(layer, g.AddFunc("layer", func() {
+				for _, dep := range depIds {
+					if atomic.LoadInt32(&done[dep]) == 0 {
+						t.Errorf("task %d ran before its dependency %d", id, dep)
+					}
+				}
+				atomic.StoreInt32(&done[id], 1)
+			}, deps...).Hint(l%2, k))
+		}
+		last = layer
+	}
+	return g, done
+}
+
+func TestGraphDependencies(t *testing.T) {
+	const layers, width = 20, 8
+	executors := map[string]func() ExecutorService{
+		"ws": func() ExecutorService { return NewWorkStealingExecutor(4, 2) },
+		"wb": func() ExecutorService { return NewWorkBalancingExecutor(4, 2, 1) },
+		"priority locality": func() ExecutorService {
+			return NewWorkStealingExecutorWithOptions(4, 2, ExecutorOptions{Priorities: true, Locality: true})
+		},
+		"bounded": func() ExecutorService {
+			return NewWorkStealingExecutorWithOptions(4, 2, ExecutorOptions{QueueCapacity: 2})
+		},
+		"go": func() ExecutorService { return &goExecutor{} },
+	}
+	for name, newExecutor := range executors {
+		t.Run(name, func(t *testing.T) {
+			e := newExecutor()
+			defer e.Shutdown()
+			g, done := layeredGraph(t, layers, width)
+			// THE GRAPH RUNS AGAIN ONCE RUN RETURNS
+			for run := 0; run < 3; run++ {
+				for i := range done {
+					done[i] = 0
+				}
+				stats := g.Run(e)
+				for i := range done {
+					if done[i] == 0 {
+						t.Fatalf("run %d: task %d did not run", run, i)
+					}
+				}
+				if stats.Tasks != layers*width || stats.Edges != 2*(layers-1)*width || stats.Depth != layers {
+					t.Fatalf("run %d: stats %+v", run, stats)
+				}
+			}
+		})
+	}
+}
+
+// without an executor every task runs on the calling goroutine, in the
+// order the tasks became ready
+func TestGraphInline(t *testing.T) {
+	g := NewGraph()
+	var order []string
+	record := func(name string) func() {
+		return func() { order = append(order, name) }
+	}
+	a := g.AddFunc("a", record("a"))
+	b := g.AddFunc("b", record("b"))
+	c := g.AddFunc("c", record("c"), a)
+	ab := g.Join("ab", a, b)
+	d := g.AddFunc("d", record("d"), ab, c)
+	g.AddFunc("e", record("e"), b)
+	g.AddFunc("f", record("f"), d)
+
+	stats := g.Run(nil)
+	want := []string{"a", "b", "c", "e", "d", "f"}
+	if len(order) != len(want) {
+		t.Fatalf("order %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order %v, want %v", order, want)
+		}
+	}
+	if stats.Peak != 1 || stats.Depth != 4 {
+		t.Fatalf("stats %+v, want a peak of 1 and a depth of 4", stats)
+	}
+}
+
+// a task that cannot be queued because the queues are full runs on the
+// goroutine that made it ready, so the graph completes while the only
+// worker is blocked
+func TestGraphFullQueueInline(t *testing.T) {
+	for _, executor := range boundedExecutors {
+		t.Run(executor.name, func(t *testing.T) {
+			e := executor.new(1, ExecutorOptions{QueueCapacity: 1})
+			defer e.Shutdown()
+			started, gate := make(chan struct{}), make(chan struct{})
+			var once sync.Once
+			release := func() {
+				once.Do(func() { close(gate) })
+			}
+			defer release()
+			e.Submit(runnableFunc(func() {
+				close(started)
+				<-gate
+			}))
+			<-started
+			// THE WORKER IS BLOCKED AND THIS TASK TAKES THE ONLY PLACE
+			e.Submit(runnableFunc(func() {}))
+
+			g := NewGraph()
+			var ran int32
+			count := func() { atomic.AddInt32(&ran, 1) }
+			a := g.AddFunc("a", count)
+			b := g.AddFunc("b", count, a)
+			g.AddFunc("c", count, a, b)
+			g.AddFunc("d", count)
+
+			finished := make(chan GraphStats)
+			go func() {
+				finished <- g.Run(e)
+			}()
+			select {
+			case stats := <-finished:
+				if ran != 4 || stats.Tasks != 4 {
+					t.Fatalf("%d tasks ran, stats %+v", ran, stats)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the graph waits for full queues")
+			}
+		})
+	}
+}
+
+// the statistics of a diamond whose tasks ran at known times
+func TestGraphStats(t *testing.T) {
+	g := NewGraph()
+	noop := func() {}
+	a := g.AddFunc("force", noop)
+	b := g.AddFunc("force", noop, a)
+	c := g.AddFunc("integrate", noop, a)
+	join := g.Join("join", b, c)
+	d := g.AddFunc("integrate", noop, join)
+	g.Run(nil)
+
+	ms := time.Millisecond
+	times := []struct {
+		node       *GraphNode
+		start, end time.Duration
+	}{
+		{a, 0, 10 * ms},
+		{b, 10 * ms, 30 * ms},
+		{c, 10 * ms, 15 * ms},
+		{join, 30 * ms, 30 * ms},
+		{d, 30 * ms, 35 * ms},
+	}
+	for _, at := range times {
+		at.node.Start, at.node.End = at.start, at.end
+	}
+	stats := g.stats()
+
+	// THE JOIN IS NOT A TASK OF THE LONGEST CHAIN A, B, D
+	if stats.Tasks != 5 || stats.Edges != 5 || stats.Depth != 3 {
+		t.Fatalf("stats %+v, want 5 tasks, 5 edges and a depth of 3", stats)
+	}
+	if stats.CriticalPath != 35*ms || stats.Work != 40*ms || stats.Wall != 35*ms {
+		t.Fatalf("critical path %v, work %v and wall %v, want 35ms, 40ms and 35ms",
+			stats.CriticalPath, stats.Work, stats.Wall)
+	}
+	if stats.Phases["force"] != 30*ms || stats.Phases["integrate"] != 10*ms || len(stats.Phases) != 2 {
+		t.Fatalf("phases %v", stats.Phases)
+	}
+	if p := stats.Parallelism(); p != 40.0/35.0 {
+		t.Fatalf("parallelism %g, want %g", p, 40.0/35.0)
+	}
+
+	sum := stats.Add(stats)
+	if sum.Runs != 2 || sum.Depth != 3 || sum.CriticalPath != 70*ms || sum.Phases["force"] != 60*ms {
+		t.Fatalf("sum of two runs %+v", sum)
+	}
+	if stats.Phases["force"] != 30*ms {
+		t.Fatal("Add changed the phases of a run")
+	}
+}
@@ -0,0 +1,135 @@
+package concurrent
+
+import (
+	"sync"
+)
+
+// Prioritized is implemented by tasks with a priority, tasks of a higher
+// priority are popped first from priority queues. Tasks without one have
+// priority 0.
+type Prioritized interface {
+	Priority() int
+}
+
+// Localized is implemented by tasks with a locality key, e.g. the index of
+// the chunk of bodies they work on. With locality enabled a task goes to
+// the worker that ran the last task of its key. Negative keys are no key.
+type Localized interface {
+	Locality() int
+}
+
+// NoLocality is the locality key of tasks without a preferred worker
+const NoLocality = -1
+
+// return the priority of a task, 0 if it has none
+func taskPriority(task interface{}) int {
+	if p, ok := task.(Prioritized); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
+// return the locality key of a task, NoLocality if it has none
+func taskLocality(task interface{}) int {
+	if l, ok := task.(Localized); ok {
+		return l.Locality()
+	}
+	return NoLocality
+}
+
+// tasks of one priority in the order they were pushed
+type priorityLevel struct {
+	priority int
+	tasks    DEQueue
+}
+
+type priorityDEQueue struct {
+	levels []*priorityLevel // Non empty levels by decreasing priority
+	lock   *sync.Mutex
+	size   int
+}
+
+// NewPriorityDEQueue returns an empty DEQueue whose tops and bottoms are
+// those of the tasks of the highest priority. Tasks of the same priority
+// keep the order of an UnBoundedDEQueue.
+func NewPriorityDEQueue() DEQueue {
+	return &priorityDEQueue{lock: &sync.Mutex{}}
+}
+
+func (q *priorityDEQueue) PushBottom(task Task) {
+	priority := taskPriority(task)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	// FEW DISTINCT PRIORITIES ARE EXPECTED, A LINEAR SEARCH IS ENOUGH
+	k := 0
+	for k < len(q.levels) && q.levels[k].priority > priority {
+		k++
+	}
+	if k == len(q.levels) || q.levels[k].priority != priority {
+		q.levels = append(q.levels, nil)
+		copy(q.levels[k+1:], q.levels[k:])
+		q.levels[k] = &priorityLevel{priority: priority, tasks: NewUnBoundedDEQueue()}
+	}
+	q.levels[k].tasks.PushBottom(task)
+	q.size++
+}
+
+func (q *priorityDEQueue) PopBottom() Task {
+	return q.pop(DEQueue.PopBottom)
+}
+
+func (q *priorityDEQueue) PopTop() Task {
+	return q.pop(DEQueue.PopTop)
+}
+
+// pop a task of the highest priority from one end of its level
+func (q *priorityDEQueue) pop(end func(DEQueue) Task) Task {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.levels) == 0 {
+		return nil
+	}
+	level := q.levels[0]
+	task := end(level.tasks)
+	if level.tasks.IsEmpty() {
+		q.levels = q.levels[1:]
+	}
+	q.size--
+	return task
+}
+
+func (q *priorityDEQueue) IsEmpty() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.size == 0
+}
+
+func (q *priorityDEQueue) Size() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.size
+}
+
+// ExecutorOptions are the optional features of the executors of this
+// package, the zero value is the original executor
+type ExecutorOptions struct {
+	// Queue tasks by priority instead of in submission order, in the
+	// global queue and in the local queues of the workers
+	Priorities bool
+	// Queue tasks with a locality key on the local queue of the worker that
+	// ran the last task of the key, other workers can still steal them
+	Locality bool
+	// Most tasks queued and not started yet, on the global queue or on the
+	// local queues of the workers, 0 is unbounded. Submit blocks while there
+	// are as many and TrySubmit fails.
+	QueueCapacity int
+}
+
+// return an empty queue of an executor with the options
+func (o ExecutorOptions) newQueue() DEQueue {
+	if o.Priorities {
+		return NewPriorityDEQueue()
+	}
+	return NewUnBoundedDEQueue()
+}
@@ -0,0 +1,140 @@
+package concurrent
+
+import (
+	"testing"
+)
+
+// task of a priority, identified by the order it was pushed in
+type prioritizedTask struct {
+	id, priority int
+}
+
+func (task prioritizedTask) Priority() int {
+	return task.priority
+}
+
+func TestPriorityDEQueue(t *testing.T) {
+	q := NewPriorityDEQueue()
+	priorities := []int{0, 2, -1, 2, 0, 1, 2, -1, 0}
+	for id, priority := range priorities {
+		q.PushBottom(prioritizedTask{id, priority})
+	}
+	if q.Size() != len(priorities) {
+		t.Fatalf("size %d, want %d", q.Size(), len(priorities))
+	}
+
+	// THE TOP OF A LEVEL IS ITS OLDEST TASK AND THE BOTTOM ITS NEWEST
+	if task := q.PopBottom().(prioritizedTask); task.id != 6 {
+		t.Fatalf("PopBottom popped task %d, want the newest task of priority 2", task.id)
+	}
+	want := []int{1, 3, 5, 0, 4, 8, 2, 7}
+	for _, id := range want {
+		task := q.PopTop().(prioritizedTask)
+		if task.id != id {
+			t.Fatalf("PopTop popped task %d of priority %d, want task %d", task.id, task.priority, id)
+		}
+	}
+	if !q.IsEmpty() || q.PopTop() != nil || q.PopBottom() != nil {
+		t.Fatal("the queue is not empty once every task is popped")
+	}
+}
+
+// the only worker of an executor with priorities runs the tasks queued
+// while it was busy by decreasing priority
+func TestPriorityExecutor(t *testing.T) {
+	const tasks = 12
+	for _, executor := range boundedExecutors {
+		t.Run(executor.name, func(t *testing.T) {
+			e := executor.new(1, ExecutorOptions{Priorities: true})
+			defer e.Shutdown()
+			gate := make(chan struct{})
+			blocker := e.Submit(runnableFunc(func() { <-gate }))
+
+			var ran []int
+			futures := make([]Future, tasks)
+			for i := range futures {
+				priority := i % 3
+				futures[i] = SubmitWith(e, runnableFunc(func() {
+					ran = append(ran, priority)
+				}), priority, NoLocality)
+			}
+			close(gate)
+			blocker.Get()
+			for _, f := range futures {
+				f.Get()
+			}
+			for i := 1; i < len(ran); i++ {
+				if ran[i] > ran[i-1] {
+					t.Fatalf("priorities ran in the order %v", ran)
+				}
+			}
+		})
+	}
+}
+
+// return an executor of workers whose workers are not spawned, so its
+// queues keep the tasks pushed to them
+func idleExecutor(workers int, options ExecutorOptions) *ExecService {
+	e := newExecService(16, options)
+	queues := make([]DEQueue, workers)
+	for i := range queues {
+		queues[i] = options.newQueue()
+	}
+	e.localQueueList.Store(queues)
+	e.capacity = int32(workers)
+	return e
+}
+
+// with locality a task goes to the local queue of the worker that ran the
+// last task of its key, unless that worker is parked
+func TestLocality(t *testing.T) {
+	e := idleExecutor(4, ExecutorOptions{Locality: true})
+	noop := runnableFunc(func() {})
+	e.execute(2, NewFuture(keyedTask{noop}))
+	e.execute(3, NewFuture(keyedTask{noop}))
+	other := NewFuture(noop)
+	other.locality = 7
+	e.execute(1, other)
+
+	tests := []struct {
+		name     string
+		locality int
+		capacity int
+		queue    int // -1 for the global queue
+	}{
+		{"last worker of the key", 0, 4, 3},
+		{"other key", 7, 4, 1},
+		{"key never run", 5, 4, -1},
+		{"no key", NoLocality, 4, -1},
+		{"parked worker", 0, 3, -1},
+		{"active worker after a resize", 7, 2, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e.capacity = int32(test.capacity)
+			f := SubmitWith(e, noop, 0, test.locality)
+			queue := e.globalQueue
+			if test.queue >= 0 {
+				queue = e.localQueues()[test.queue]
+			}
+			if task := queue.PopTop(); task != f {
+				t.Fatal("the task was not queued on the expected queue")
+			}
+			if !e.globalQueue.IsEmpty() {
+				t.Fatal("the task was also queued on the global queue")
+			}
+			for i, q := range e.localQueues() {
+				if !q.IsEmpty() {
+					t.Fatalf("the task was also queued on worker %d", i)
+				}
+			}
+		})
+	}
+
+	// WITHOUT THE OPTION THE KEYS ARE IGNORED
+	e.options.Locality = false
+	e.capacity = 4
+	if f := SubmitWith(e, noop, 0, 0); e.globalQueue.PopTop() != f {
+		t.Fatal("a task was routed by its key without locality")
+	}
+}
@@ -2,6 +2,7 @@ package concurrent
 
 import (
 	"math/rand"
+	"runtime"
 	"sync"
 	"time"
 )
@@ -14,25 +15,24 @@ import (
 // once to place into their local queue before grabbing more items. It's
 // not required that you use this parameter in your implementation.
 func NewWorkStealingExecutor(capacity, threshold int) ExecutorService {
-	globalQueue := NewUnBoundedDEQueue()
-	localQueueList := make([]DEQueue, capacity)
-	execService := &ExecService{
-		capacity:       capacity,
-		threshold:      threshold,
-		globalQueue:    globalQueue,
-		localQueueList: localQueueList,
-		done:           false,
-		wg:             &sync.WaitGroup{},
-	}
+	return NewWorkStealingExecutorWithOptions(capacity, threshold, ExecutorOptions{})
+}
+
+// NewWorkStealingExecutorWithOptions returns the executor of NewWorkStealingExecutor with optional features
+func NewWorkStealingExecutorWithOptions(capacity, threshold int, options ExecutorOptions) ExecutorService {
+	execService := newExecService(threshold, options)
 
 	worker := func(execService *ExecService, workerId int, wg *sync.WaitGroup) {
 		defer wg.Done()
 		for {
+			// PARK WHILE THE EXECUTOR IS RESIZED BELOW THIS WORKER
+			execService.park(workerId)
+			localQueueList := execService.localQueues()
 
 			// BREAKING CONDITION
-			if execService.done &&
+			if execService.isDone() &&
 				execService.globalQueue.IsEmpty() &&
-				execService.localQueueList[workerId].IsEmpty() {
+				localQueueList[workerId].IsEmpty() {
 				break
 			}
 
@@ -42,71 +42,51 @@ func NewWorkStealingExecutor(capacity, threshold int) ExecutorService {
 				if f == nil {
 					break
 				}
-				execService.localQueueList[workerId].PushBottom(f)
+				localQueueList[workerId].PushBottom(f)
 			}
 
 			// TRY STEALING TASKS
 			// SINCE WE COULDN'T GET ANY MORE TASKS FROM GLOBAL QUEUE
-			if execService.localQueueList[workerId].IsEmpty() {
+			// A SINGLE WORKER HAS NO VICTIM
+			workers := execService.activeWorkers(localQueueList)
+			if localQueueList[workerId].IsEmpty() && workers > 1 {
 				// WORK-STEALING ALGORITHM
 				steal := func(victim int) {
 					for i := 0; i < execService.threshold; i++ {
-						f := execService.localQueueList[victim].PopTop()
+						f := localQueueList[victim].PopTop()
 						if f == nil {
 							continue
 						}
-						execService.localQueueList[workerId].PushBottom(f)
+						localQueueList[workerId].PushBottom(f)
 					}
 				}
 
 				// STEAL FROM VICTIM QUEUE
-				victim := random(execService.capacity, workerId)
+				victim := random(workers, workerId)
 				steal(victim)
 
 			}
 
+			// AN IDLE WORKER YIELDS, E.G. TO A PRODUCER BLOCKED ON FULL QUEUES
+			if localQueueList[workerId].IsEmpty() {
+				runtime.Gosched()
+			}
+
 			// WORK ON TASKS IN THE LOCAL QUEUE
-			for !execService.localQueueList[workerId].IsEmpty() {
-				f_ := execService.localQueueList[workerId].PopBottom()
+			for !localQueueList[workerId].IsEmpty() {
+				f_ := localQueueList[workerId].PopBottom()
 				if f_ == nil {
 					break
 				}
 
-				f := f_.(*future)
-				if task, ok := f.Task.(interface{ Call() interface{} }); ok {
-					f.Promise <- task.Call()
-				} else {
-					task := f.Task.(interface{ Run() })
-					task.Run()
-					f.Promise <- nil
-				}
-				close(f.Promise)
+				execService.execute(workerId, f_)
 			}
 		}
 	}
 
-	execService.wg.Add(1)
-	{
-		go func(e *ExecService) {
-			var wg sync.WaitGroup
-			wg.Add(capacity)
-
-			for i := 0; i < capacity; i++ {
-				localQueueList[i] = NewUnBoundedDEQueue()
-			}
-
-			// SPAWN WORKERS
-			for i := 0; i < capacity; i++ {
-				go worker(e, i, &wg)
-			}
-
-			// WAIT FOR EVERYONE TO FINISH WORKING
-			wg.Wait()
-
-			// NOTIFY SHUTDOWN ALL TASKS ARE COMPLETED
-			execService.wg.Done()
-		}(execService)
-	}
+	// SPAWN WORKERS
+	execService.worker = worker
+	execService.Resize(capacity)
 
 	return execService
 }
@@ -0,0 +1,65 @@
+package concurrent
+
+import "sync"
+
+// TypedCallable is a Callable whose result has a static type, so it is not
+// boxed in an interface{}
+type TypedCallable[T any] interface {
+	Call() T
+}
+
+// TypedFuture is the result of a TypedCallable submitted with
+// SubmitCallable. It is named TypedFuture because Future is taken by the
+// original interface.
+type TypedFuture[T any] struct {
+	task  TypedCallable[T]
+	value T
+	wg    sync.WaitGroup
+}
+
+// Get waits for the task to complete and returns the value returned by its
+// Call method
+func (f *TypedFuture[T]) Get() T {
+	f.wg.Wait()
+	return f.value
+}
+
+// Run calls the task and completes the future, a TypedFuture is queued as a
+// Runnable
+func (f *TypedFuture[T]) Run() {
+	f.value = f.task.Call()
+	f.wg.Done()
+}
+
+// Priority returns the priority of the task, if it has one
+func (f *TypedFuture[T]) Priority() int {
+	return taskPriority(f.task)
+}
+
+// Locality returns the locality key of the task, if it has one
+func (f *TypedFuture[T]) Locality() int {
+	return taskLocality(f.task)
+}
+
+// SubmitCallable submits a typed task for execution and returns a future of
+// its result. On the executors of this package the future is queued as it
+// is, without a promise channel; other executors run it as a Runnable.
+func SubmitCallable[T any](e ExecutorService, task TypedCallable[T]) *TypedFuture[T] {
+	f := &TypedFuture[T]{task: task}
+	f.wg.Add(1)
+	enqueue(e, f)
+	return f
+}
+
+// function adapting a func() T to a TypedCallable
+type callableFunc[T any] func() T
+
+func (fn callableFunc[T]) Call() T {
+	return fn()
+}
+
+// SubmitFunc submits a function for execution and returns a future of its
+// result
+func SubmitFunc[T any](e ExecutorService, fn func() T) *TypedFuture[T] {
+	return SubmitCallable[T](e, callableFunc[T](fn))
+}
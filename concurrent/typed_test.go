@@ -0,0 +1,110 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+)
+
+// executor of another package: it runs every task on a new goroutine
+type goExecutor struct {
+	wg sync.WaitGroup
+}
+
+type doneFuture struct{}
+
+func (doneFuture) Get() interface{} {
+	return nil
+}
+
+func (e *goExecutor) Submit(task interface{}) Future {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		task.(Runnable).Run()
+	}()
+	return doneFuture{}
+}
+
+func (e *goExecutor) Shutdown() {
+	e.wg.Wait()
+}
+
+// executors of this package and of another one
+func typedExecutors() map[string]ExecutorService {
+	return map[string]ExecutorService{
+		"ws": NewWorkStealingExecutor(4, 2),
+		"wb": NewWorkBalancingExecutor(4, 2, 1),
+		"go": &goExecutor{},
+	}
+}
+
+// callable task squaring a number, with queueing hints
+type squareTask struct {
+	n int
+}
+
+func (task squareTask) Call() int {
+	return task.n * task.n
+}
+
+func (task squareTask) Priority() int {
+	return task.n % 3
+}
+
+func (task squareTask) Locality() int {
+	return task.n
+}
+
+func TestSubmitCallable(t *testing.T) {
+	const tasks = 1000
+	for name, e := range typedExecutors() {
+		t.Run(name, func(t *testing.T) {
+			defer e.Shutdown()
+			futures := make([]*TypedFuture[int], tasks)
+			for i := range futures {
+				futures[i] = SubmitCallable[int](e, squareTask{i})
+			}
+			for i, f := range futures {
+				if v := f.Get(); v != i*i {
+					t.Fatalf("task %d returned %d", i, v)
+				}
+				// A SECOND Get RETURNS THE SAME VALUE WITHOUT WAITING
+				if v := f.Get(); v != i*i {
+					t.Fatalf("task %d returned %d the second time", i, v)
+				}
+			}
+		})
+	}
+}
+
+func TestSubmitFunc(t *testing.T) {
+	for name, e := range typedExecutors() {
+		t.Run(name, func(t *testing.T) {
+			defer e.Shutdown()
+			words := SubmitFunc(e, func() []string { return []string{"typed", "future"} })
+			half := SubmitFunc(e, func() float64 { return 0.5 })
+			if got := words.Get(); len(got) != 2 || got[1] != "future" {
+				t.Fatalf("returned %v", got)
+			}
+			if got := half.Get(); got != 0.5 {
+				t.Fatalf("returned %g", got)
+			}
+		})
+	}
+}
+
+// a future is queued with the hints of its task
+func TestTypedFutureHints(t *testing.T) {
+	e := &goExecutor{}
+	defer e.Shutdown()
+	f := SubmitCallable[int](e, squareTask{5})
+	if f.Priority() != 2 || f.Locality() != 5 {
+		t.Fatalf("priority %d and locality %d, want 2 and 5", f.Priority(), f.Locality())
+	}
+	g := SubmitFunc(e, func() int { return 1 })
+	if g.Priority() != 0 || g.Locality() != NoLocality {
+		t.Fatalf("priority %d and locality %d of a task without hints", g.Priority(), g.Locality())
+	}
+	f.Get()
+	g.Get()
+}
@@ -1,25 +1,327 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"math"
 	"os"
+	"proj3/nbody"
+	"proj3/render"
 	"proj3/scheduler"
+	"proj3/server"
+	"proj3/snapshot"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const usage = "USAGE: go run editor.go -m <mode: \"s\" or \"ws\" or \"wb\"> -n <number of bodies> " +
 	"-i <number of timesteps> -r <record positions> -t <number of threads> " +
-	"-p <print config to console>" +
+	"-p <print config to console> -c <csv columns> -d <csv precision> -f <record format: \"csv\" or \"bin\">" +
+	" -l <live view every K steps> -lp <live view projection: xy, xz, yz, 3d> -http <address of the viewer, e.g. localhost:8080>" +
+	" -eta <adaptive timestep accuracy> -dtmin <smallest timestep> -dtmax <largest timestep> -diag <diagnostics csv file>" +
+	" -globals <global diagnostics csv file> -globalsevery <steps between global diagnostics>" +
+	" -blocks <number of block timestep levels> -soft <softening kernel: plummer, spline, none> -eps <softening length>" +
+	" -collisions <merge or bounce> -radius <body radius> -collog <collision log csv file>" +
+	" -box <side of the periodic box> -noewald <minimum image forces only>" +
+	" -law <force law, e.g. yukawa:lambda=50, coulomb:k=1,q=1, lennard-jones:epsilon=1,sigma=1, 1pn:c=1e4>" +
+	" -ext <external potential, e.g. nfw:mass=1e6,a=20> -extfile <json list of external potentials>" +
+	" -solver <direct, pm or fmm> -mesh <particle mesh cells per side> -order <fmm order> -theta <fmm opening angle>" +
+	" -graph <run fixed direct and pm steps as task graphs> -hints <priority and locality aware task queues>" +
+	" -elastic <resize the workers between phases> -queue <most tasks queued and not started, 0 is unbounded>" +
+	"\n       go run editor.go convert <input file> <output file> [-c <columns>] [-d <csv precision>]" +
+	"\n       go run editor.go render <snapshot file> <output .gif or png directory> [-v <view: xy, xz, yz, 3d>]" +
+	" [-b <bounds, 0 fits>] [-c <color: cluster, speed, mass>] [-s <image size>] [-fps <frames per second>]" +
+	"\n       go run editor.go accuracy [-m <mode>] [-t <threads>] [-n <bodies>] [-theta <fmm opening angle>] [-orders <fmm orders, e.g. 1,2,4,6>]" +
 	"\n Minimum value for number of bodies is 2000 and iterations is 10"
 
+// convert a snapshot file between csv and the binary format, the output
+// format is chosen by the extension of the output file (".csv" or ".nbs")
+func convert(args []string) {
+	if len(args) < 2 {
+		panic(usage)
+	}
+	precision := 6
+	columns := ""
+	var err error
+	for i := 2; i < len(args); i++ {
+		if args[i] == "-d" {
+			precision, err = strconv.Atoi(args[i+1])
+			if err != nil {
+				fmt.Println("Invalid value for csv precision given")
+				panic(err)
+			}
+			i++
+		} else if args[i] == "-c" {
+			columns = args[i+1]
+			i++
+		} else {
+			fmt.Println("INVALID COMMAND LINE ARGUMENT GIVEN")
+			panic(usage)
+		}
+	}
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		fmt.Println("ERROR WHEN OPENING FILE \"" + args[0] + "\"")
+		panic(err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(args[1])
+	if err != nil {
+		fmt.Println("ERROR WHEN OPENING FILE \"" + args[1] + "\"")
+		panic(err)
+	}
+	defer out.Close()
+
+	var dst snapshot.FrameWriter
+	if strings.HasSuffix(args[1], ".csv") {
+		dst = snapshot.NewCSVWriter(out, precision)
+	} else {
+		dst = &binaryFrameWriter{out: out}
+	}
+
+	var src snapshot.FrameReader = snapshot.NewFrameReader(in)
+	if columns != "" {
+		src = &selectFields{src: src, fields: strings.Split(columns, ",")}
+	}
+
+	frames, err := snapshot.Convert(dst, src)
+	if err != nil {
+		fmt.Println("ERROR WHEN CONVERTING \"" + args[0] + "\"")
+		panic(err)
+	}
+	fmt.Printf("CONVERTED %d FRAMES FROM %s TO %s\n", frames, args[0], args[1])
+}
+
+// creates the binary writer once the fields of the first frame are known
+type binaryFrameWriter struct {
+	out *os.File
+	w   *snapshot.Writer
+}
+
+func (b *binaryFrameWriter) Write(f *snapshot.Frame) error {
+	if b.w == nil {
+		w, err := snapshot.NewWriter(b.out, f.Fields)
+		if err != nil {
+			return err
+		}
+		b.w = w
+	}
+	return b.w.Write(f)
+}
+
+// keeps only the given fields of every frame
+type selectFields struct {
+	src    snapshot.FrameReader
+	fields []string
+}
+
+func (s *selectFields) Next() (*snapshot.Frame, error) {
+	f, err := s.src.Next()
+	if err != nil {
+		return nil, err
+	}
+	out := snapshot.NewFrame(s.fields, f.N)
+	out.Step, out.Time = f.Step, f.Time
+	for c, field := range s.fields {
+		src := f.Column(strings.TrimSpace(field))
+		if src < 0 {
+			return nil, fmt.Errorf("field %q not in snapshot", field)
+		}
+		for i := 0; i < f.N; i++ {
+			out.Data[i*len(s.fields)+c] = f.Value(i, src)
+		}
+	}
+	return out, nil
+}
+
+// render a snapshot file to an animated gif, or to png frames if the
+// output does not end in ".gif"
+func renderSnapshot(args []string) {
+	if len(args) < 2 {
+		panic(usage)
+	}
+	opts := render.DefaultOptions()
+	fps := 15
+	var err error
+	for i := 2; i < len(args); i++ {
+		if args[i] == "-v" {
+			opts.View = args[i+1]
+			i++
+		} else if args[i] == "-b" {
+			opts.Bounds, err = strconv.ParseFloat(args[i+1], 64)
+			if err != nil {
+				fmt.Println("Invalid value for bounds given")
+				panic(err)
+			}
+			i++
+		} else if args[i] == "-c" {
+			opts.Color = args[i+1]
+			i++
+		} else if args[i] == "-s" {
+			opts.Width, err = strconv.Atoi(args[i+1])
+			if err != nil {
+				fmt.Println("Invalid value for image size given")
+				panic(err)
+			}
+			opts.Height = opts.Width
+			i++
+		} else if args[i] == "-fps" {
+			fps, err = strconv.Atoi(args[i+1])
+			if err != nil {
+				fmt.Println("Invalid value for frames per second given")
+				panic(err)
+			}
+			i++
+		} else {
+			fmt.Println("INVALID COMMAND LINE ARGUMENT GIVEN")
+			panic(usage)
+		}
+	}
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		fmt.Println("ERROR WHEN OPENING FILE \"" + args[0] + "\"")
+		panic(err)
+	}
+	defer in.Close()
+
+	frames, err := snapshot.ReadAll(snapshot.NewFrameReader(in))
+	if err != nil {
+		fmt.Println("ERROR WHEN READING \"" + args[0] + "\"")
+		panic(err)
+	}
+
+	if strings.HasSuffix(args[1], ".gif") {
+		out, err := os.Create(args[1])
+		if err != nil {
+			fmt.Println("ERROR WHEN OPENING FILE \"" + args[1] + "\"")
+			panic(err)
+		}
+		defer out.Close()
+		err = render.WriteGIF(out, frames, opts, fps)
+	} else {
+		err = render.WritePNGs(args[1], frames, opts)
+	}
+	if err != nil {
+		fmt.Println("ERROR WHEN RENDERING \"" + args[0] + "\"")
+		panic(err)
+	}
+	fmt.Printf("RENDERED %d FRAMES TO %s\n", len(frames), args[1])
+}
+
+// compare the accuracy and the speed of the multipole solver of several
+// orders against the direct sum on the initial bodies
+func accuracy(args []string) {
+	var config scheduler.Config
+	config.Mode = "s"
+	config.NBodies = 3000
+	config.ThreadCount = 4
+	orders := []int{1, 2, 4, 6, 8}
+	var err error
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-m" {
+			config.Mode = args[i+1]
+			i++
+		} else if args[i] == "-t" || args[i] == "-n" {
+			value, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				fmt.Println("Invalid value for " + args[i] + " given")
+				panic(err)
+			}
+			if value < 1 {
+				panic("Minimum value for " + args[i] + " is 1")
+			}
+			if args[i] == "-t" {
+				config.ThreadCount = value
+			} else {
+				config.NBodies = value
+			}
+			i++
+		} else if args[i] == "-theta" {
+			theta, err := strconv.ParseFloat(args[i+1], 32)
+			if err != nil {
+				fmt.Println("Invalid value for opening angle given")
+				panic(err)
+			}
+			config.FMMTheta = float32(theta)
+			i++
+		} else if args[i] == "-orders" {
+			orders = nil
+			for _, field := range strings.Split(args[i+1], ",") {
+				order, err := strconv.Atoi(strings.TrimSpace(field))
+				if err != nil {
+					fmt.Println("Invalid value for fmm orders given")
+					panic(err)
+				}
+				orders = append(orders, order)
+			}
+			i++
+		} else {
+			fmt.Println("INVALID COMMAND LINE ARGUMENT GIVEN")
+			panic(usage)
+		}
+	}
+	if config.Mode != "s" && config.Mode != "ws" && config.Mode != "wb" {
+		panic("Invalid scheduling scheme: " + config.Mode)
+	}
+
+	fmt.Printf("ACCELERATIONS OF %d BODIES, MODE %s\n", config.NBodies, config.Mode)
+	err = scheduler.WriteSolverAccuracy(os.Stdout, scheduler.CompareSolvers(config, orders))
+	if err != nil {
+		panic(err)
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		convert(os.Args[2:])
+		return
+	} else if len(os.Args) > 1 && os.Args[1] == "render" {
+		renderSnapshot(os.Args[2:])
+		return
+	} else if len(os.Args) > 1 && os.Args[1] == "accuracy" {
+		accuracy(os.Args[2:])
+		return
+	}
+
 	mode := "s"
 	numBodies := 10_000
 	iterations := 100
 	recordPositions := "no"
 	threadCount := 64
 	printConfigToConsole := false
+	csvColumns := "state"
+	csvPrecision := 6
+	recordFormat := "csv"
+	liveEvery := 0
+	liveProjection := "xy"
+	httpAddr := ""
+	var timestepEta, timestepMin, timestepMax float64
+	diagnosticsFile := ""
+	globalsFile := ""
+	globalsEvery := 10
+	taskGraph := false
+	taskHints := false
+	elastic := false
+	queueCapacity := 0
+	blockLevels := 0
+	softeningKernel := "plummer"
+	softeningLength := 0.01
+	collisions := "none"
+	bodyRadius := 0.0
+	collisionLog := ""
+	boxSize := 0.0
+	ewald := true
+	solver := "direct"
+	meshCells := 32
+	fmmOrder := 4
+	fmmTheta := 0.5
+	var external []nbody.ExternalSpec
+	forceLaw := "gravity"
 	var err error
 
 	for i := 1; i < len(os.Args); i++ {
@@ -58,6 +360,192 @@ func main() {
 		} else if os.Args[i] == "-p" {
 			printConfigToConsole = true
 
+		} else if os.Args[i] == "-c" {
+			csvColumns = os.Args[i+1]
+			i++
+		} else if os.Args[i] == "-f" {
+			recordFormat = os.Args[i+1]
+			if recordFormat != "csv" && recordFormat != "bin" {
+				panic("Record format must be \"csv\" or \"bin\"")
+			}
+			i++
+		} else if os.Args[i] == "-l" {
+			liveEvery, err = strconv.Atoi(os.Args[i+1])
+			if err != nil {
+				fmt.Println("Invalid value for live view interval given")
+				panic(err)
+			}
+			i++
+		} else if os.Args[i] == "-http" {
+			httpAddr = os.Args[i+1]
+			i++
+		} else if os.Args[i] == "-eta" || os.Args[i] == "-dtmin" || os.Args[i] == "-dtmax" {
+			value, err := strconv.ParseFloat(os.Args[i+1], 32)
+			if err != nil {
+				fmt.Println("Invalid value for " + os.Args[i] + " given")
+				panic(err)
+			}
+			if os.Args[i] == "-eta" {
+				timestepEta = value
+			} else if os.Args[i] == "-dtmin" {
+				timestepMin = value
+			} else {
+				timestepMax = value
+			}
+			i++
+		} else if os.Args[i] == "-blocks" {
+			blockLevels, err = strconv.Atoi(os.Args[i+1])
+			if err != nil || blockLevels < 0 || blockLevels > 20 {
+				panic("Number of block timestep levels must be between 0 and 20")
+			}
+			i++
+		} else if os.Args[i] == "-soft" {
+			softeningKernel = os.Args[i+1]
+			i++
+		} else if os.Args[i] == "-eps" {
+			softeningLength, err = strconv.ParseFloat(os.Args[i+1], 32)
+			if err != nil {
+				fmt.Println("Invalid value for softening length given")
+				panic(err)
+			}
+			if softeningLength < 0 {
+				panic("Softening length must not be negative")
+			}
+			i++
+		} else if os.Args[i] == "-collisions" {
+			collisions = os.Args[i+1]
+			if collisions != "none" && collisions != "merge" && collisions != "bounce" {
+				panic("Collision mode must be \"none\", \"merge\" or \"bounce\"")
+			}
+			i++
+		} else if os.Args[i] == "-radius" {
+			bodyRadius, err = strconv.ParseFloat(os.Args[i+1], 32)
+			if err != nil {
+				fmt.Println("Invalid value for body radius given")
+				panic(err)
+			}
+			if bodyRadius < 0 {
+				panic("Body radius must not be negative")
+			}
+			i++
+		} else if os.Args[i] == "-box" {
+			boxSize, err = strconv.ParseFloat(os.Args[i+1], 32)
+			if err != nil {
+				fmt.Println("Invalid value for box size given")
+				panic(err)
+			}
+			if boxSize < 0 {
+				panic("Box size must not be negative")
+			}
+			i++
+		} else if os.Args[i] == "-noewald" {
+			ewald = false
+		} else if os.Args[i] == "-graph" {
+			taskGraph = true
+		} else if os.Args[i] == "-hints" {
+			taskHints = true
+		} else if os.Args[i] == "-elastic" {
+			elastic = true
+		} else if os.Args[i] == "-queue" {
+			queueCapacity, err = strconv.Atoi(os.Args[i+1])
+			if err != nil {
+				fmt.Println("Invalid value for queue capacity given")
+				panic(err)
+			}
+			if queueCapacity < 0 {
+				panic("Queue capacity must not be negative")
+			}
+			i++
+		} else if os.Args[i] == "-solver" {
+			solver = os.Args[i+1]
+			if solver != "direct" && solver != "pm" && solver != "fmm" {
+				panic("Force solver must be \"direct\", \"pm\" or \"fmm\"")
+			}
+			i++
+		} else if os.Args[i] == "-law" {
+			forceLaw = os.Args[i+1]
+			if _, err := nbody.ParseForceLaw(forceLaw, nbody.NoSoftening{}); err != nil {
+				fmt.Println("Invalid force law given")
+				panic(err)
+			}
+			i++
+		} else if os.Args[i] == "-ext" {
+			spec, err := nbody.ParseExternalSpec(os.Args[i+1])
+			if err != nil {
+				fmt.Println("Invalid external potential given")
+				panic(err)
+			}
+			external = append(external, spec)
+			i++
+		} else if os.Args[i] == "-extfile" {
+			data, err := ioutil.ReadFile(os.Args[i+1])
+			if err != nil {
+				fmt.Println("ERROR WHEN OPENING FILE \"" + os.Args[i+1] + "\"")
+				panic(err)
+			}
+			var specs []nbody.ExternalSpec
+			if err := json.Unmarshal(data, &specs); err != nil {
+				fmt.Println("Invalid external potential file given")
+				panic(err)
+			}
+			for _, spec := range specs {
+				if _, err := nbody.NewExternalPotential(spec); err != nil {
+					fmt.Println("Invalid external potential file given")
+					panic(err)
+				}
+			}
+			external = append(external, specs...)
+			i++
+		} else if os.Args[i] == "-order" {
+			fmmOrder, err = strconv.Atoi(os.Args[i+1])
+			if err != nil {
+				fmt.Println("Invalid value for fmm order given")
+				panic(err)
+			}
+			i++
+		} else if os.Args[i] == "-theta" {
+			fmmTheta, err = strconv.ParseFloat(os.Args[i+1], 32)
+			if err != nil {
+				fmt.Println("Invalid value for fmm opening angle given")
+				panic(err)
+			}
+			i++
+		} else if os.Args[i] == "-mesh" {
+			meshCells, err = strconv.Atoi(os.Args[i+1])
+			if err != nil {
+				fmt.Println("Invalid value for mesh cells given")
+				panic(err)
+			}
+			i++
+		} else if os.Args[i] == "-collog" {
+			collisionLog = os.Args[i+1]
+			i++
+		} else if os.Args[i] == "-diag" {
+			diagnosticsFile = os.Args[i+1]
+			i++
+		} else if os.Args[i] == "-globals" {
+			globalsFile = os.Args[i+1]
+			i++
+		} else if os.Args[i] == "-globalsevery" {
+			globalsEvery, err = strconv.Atoi(os.Args[i+1])
+			if err != nil {
+				fmt.Println("Invalid value for steps between global diagnostics given")
+				panic(err)
+			}
+			if globalsEvery < 1 {
+				panic("Minimum value for steps between global diagnostics is 1")
+			}
+			i++
+		} else if os.Args[i] == "-lp" {
+			liveProjection = os.Args[i+1]
+			i++
+		} else if os.Args[i] == "-d" {
+			csvPrecision, err = strconv.Atoi(os.Args[i+1])
+			if err != nil {
+				fmt.Println("Invalid value for csv precision given")
+				panic(err)
+			}
+			i++
 		} else {
 			fmt.Println("INVALID COMMAND LINE ARGUMENT GIVEN")
 			panic(usage)
@@ -71,9 +559,41 @@ func main() {
 		fmt.Println("NUMBER OF BODIES	: ", numBodies)
 		fmt.Println("NUMBER OF TIMESTEPS	: ", iterations)
 		fmt.Println("RECORD POSITIONS IN CSV	: ", recordPositions)
+		if recordPositions == "yes" {
+			fmt.Println("RECORD FORMAT		: ", recordFormat)
+			fmt.Println("CSV COLUMNS		: ", csvColumns)
+			fmt.Println("CSV PRECISION		: ", csvPrecision)
+		}
 		if mode != "s" {
 			fmt.Println("NUMBER OF THREADS	: ", threadCount)
 		}
+		fmt.Println("SOFTENING		: ", softeningKernel, softeningLength)
+		if solver == "pm" {
+			fmt.Println("FORCE SOLVER		: ", solver, meshCells)
+		} else if solver == "fmm" {
+			fmt.Println("FORCE SOLVER		: ", solver, fmmOrder, fmmTheta)
+		}
+		if liveEvery > 0 {
+			fmt.Println("LIVE VIEW EVERY		: ", liveEvery)
+		}
+		if timestepEta > 0 {
+			fmt.Println("ADAPTIVE TIMESTEP ETA	: ", timestepEta)
+		}
+		if blockLevels > 0 {
+			fmt.Println("BLOCK TIMESTEP LEVELS	: ", blockLevels)
+		}
+		if collisions != "none" {
+			fmt.Println("COLLISIONS		: ", collisions, bodyRadius)
+		}
+		if forceLaw != "gravity" {
+			fmt.Println("FORCE LAW		: ", forceLaw)
+		}
+		for _, spec := range external {
+			fmt.Printf("EXTERNAL POTENTIAL	:  %+v\n", spec)
+		}
+		if boxSize > 0 {
+			fmt.Println("PERIODIC BOX		: ", boxSize, "EWALD:", ewald)
+		}
 		fmt.Println("---------------------------------------------")
 	}
 
@@ -83,15 +603,148 @@ func main() {
 	config.Iterations = iterations
 	config.RecordPositions = recordPositions
 	config.ThreadCount = threadCount
+	config.RecordFormat = recordFormat
+	config.CSVColumns = csvColumns
+	config.CSVPrecision = csvPrecision
+	config.LiveEvery = liveEvery
+	config.LiveProjection = liveProjection
+	config.TimestepEta = float32(timestepEta)
+	config.TimestepMin = float32(timestepMin)
+	config.TimestepMax = float32(timestepMax)
+	config.BlockLevels = blockLevels
+	config.SofteningKernel = softeningKernel
+	config.SofteningLength = float32(softeningLength)
+	config.Collisions = collisions
+	config.BodyRadius = float32(bodyRadius)
+	config.BoxSize = float32(boxSize)
+	config.Ewald = ewald
+	config.Solver = solver
+	config.MeshCells = meshCells
+	config.FMMOrder = fmmOrder
+	config.FMMTheta = float32(fmmTheta)
+	config.External = external
+	config.ForceLaw = forceLaw
+	config.TaskGraph = taskGraph
+	config.TaskHints = taskHints
+	config.Elastic = elastic
+	config.QueueCapacity = queueCapacity
+	if globalsFile != "" {
+		config.DiagnosticsEvery = globalsEvery
+	}
 
+	var srv *server.Server
+	if httpAddr != "" {
+		srv, err = server.New(server.DefaultOptions(), config, iterations)
+		if err == nil {
+			err = srv.Start(httpAddr)
+		}
+		if err != nil {
+			fmt.Println("ERROR WHEN STARTING THE HTTP SERVER")
+			panic(err)
+		}
+		defer srv.Close()
+		config.OnStep = srv.Publish
+		fmt.Println("VIEWER AT http://" + srv.Addr())
+	}
+
+	var stats scheduler.Stats
 	start := time.Now()
 	{
-		scheduler.Schedule(config)
+		stats = scheduler.Schedule(config)
 	}
 	totalTime := time.Since(start).Seconds()
+	if srv != nil {
+		srv.Finish()
+	}
 	avgTime := totalTime / float64(iterations)
 
 	fmt.Printf("TOTAL TIME: %.5fs, AVG TIME: %.5fs\n", totalTime, avgTime)
+	if recordPositions == "yes" {
+		// ONLY CAPTURE AND STALL ARE ON THE CRITICAL PATH, WRITE OVERLAPS THE NEXT ITERATIONS
+		s := stats.Snapshots
+		fmt.Printf("SNAPSHOTS: %d, CAPTURE: %.5fs, STALL: %.5fs, BACKGROUND WRITE: %.5fs\n",
+			s.Frames, s.Capture.Seconds(), s.Stall.Seconds(), s.Write.Seconds())
+	}
+	if len(stats.Timesteps) > 0 {
+		minDt, maxDt, sumDt := stats.Timesteps[0].Dt, stats.Timesteps[0].Dt, float32(0)
+		for _, t := range stats.Timesteps {
+			if t.Dt < minDt {
+				minDt = t.Dt
+			}
+			if t.Dt > maxDt {
+				maxDt = t.Dt
+			}
+			sumDt += t.Dt
+		}
+		fmt.Printf("TIMESTEPS: MIN %.3e, MAX %.3e, MEAN %.3e, SIMULATED TIME %.5f\n",
+			minDt, maxDt, sumDt/float32(len(stats.Timesteps)), sumDt)
+	}
+	if stats.Substeps > 0 {
+		fmt.Printf("BLOCK TIMESTEPS: %d SUBSTEPS, %.1f%% OF BODIES ACTIVE PER SUBSTEP, BODIES PER LEVEL %v\n",
+			stats.Substeps, 100*float64(stats.ActiveUpdates)/float64(stats.Substeps*numBodies), stats.Levels)
+	}
+	if stats.Graph.Runs > 0 {
+		g := stats.Graph
+		fmt.Printf("TASK GRAPHS: %d, %d TASKS, %d EDGES, WORK %.5fs, CRITICAL PATH %.5fs, PARALLELISM %.2f, PEAK %d\n",
+			g.Runs, g.Tasks, g.Edges, g.Work.Seconds(), g.CriticalPath.Seconds(), g.Parallelism(), g.Peak)
+	}
+	if stats.Workers != nil {
+		fmt.Println("ELASTIC WORKERS PER PHASE:", stats.Workers)
+	}
+	if collisions != "none" {
+		fmt.Printf("COLLISIONS: %d, BODIES LEFT: %d\n", len(stats.Collisions), stats.Survivors)
+	}
+	if collisionLog != "" {
+		file, err := os.Create(collisionLog)
+		if err != nil {
+			fmt.Println("ERROR WHEN OPENING FILE \"" + collisionLog + "\"")
+			panic(err)
+		}
+		err = scheduler.WriteCollisionLog(file, stats)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Println("ERROR WHEN WRITING FILE \"" + collisionLog + "\"")
+			panic(err)
+		}
+	}
+	if diagnosticsFile != "" {
+		file, err := os.Create(diagnosticsFile)
+		if err != nil {
+			fmt.Println("ERROR WHEN OPENING FILE \"" + diagnosticsFile + "\"")
+			panic(err)
+		}
+		err = scheduler.WriteDiagnostics(file, stats)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Println("ERROR WHEN WRITING FILE \"" + diagnosticsFile + "\"")
+			panic(err)
+		}
+	}
+	if len(stats.Globals) > 1 {
+		first, last := stats.Globals[0], stats.Globals[len(stats.Globals)-1]
+		energy0 := first.Kinetic + first.Potential
+		fmt.Printf("ENERGY: INITIAL %.6e, FINAL %.6e, RELATIVE DRIFT %.3e\n", energy0, last.Kinetic+last.Potential,
+			(last.Kinetic+last.Potential-energy0)/math.Abs(energy0))
+	}
+	if globalsFile != "" {
+		file, err := os.Create(globalsFile)
+		if err != nil {
+			fmt.Println("ERROR WHEN OPENING FILE \"" + globalsFile + "\"")
+			panic(err)
+		}
+		err = scheduler.WriteGlobalDiagnostics(file, stats)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Println("ERROR WHEN WRITING FILE \"" + globalsFile + "\"")
+			panic(err)
+		}
+	}
 	if printConfigToConsole {
 		fmt.Println("---------------------------------------------")
 	}
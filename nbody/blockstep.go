@@ -0,0 +1,64 @@
+package nbody
+
+import "math"
+
+// BlockTimesteps gives every body its own power-of-two timestep
+// DtMax / 2^level, chosen from its own acceleration. A block step of DtMax
+// is split into 2^Levels substeps and a body only needs new forces at the
+// substeps where its own step begins.
+type BlockTimesteps struct {
+	Controller TimestepController // Per body criterion, DtMax is the level 0 step
+	Levels     int                // Deepest level
+}
+
+// Substeps returns the number of substeps in one block step
+func (bt BlockTimesteps) Substeps() int {
+	return 1 << bt.Levels
+}
+
+// SubstepDt returns the timestep of the deepest level
+func (bt BlockTimesteps) SubstepDt() float32 {
+	return bt.Controller.DtMax / float32(bt.Substeps())
+}
+
+// LevelDt returns the timestep of a level
+func (bt BlockTimesteps) LevelDt(level int) float32 {
+	return bt.Controller.DtMax / float32(int(1)<<level)
+}
+
+// return whether a step of the given level starts at substep s
+func (bt BlockTimesteps) starts(level, s int) bool {
+	return s%(1<<(bt.Levels-level)) == 0
+}
+
+// Active appends the bodies whose step starts at substep s to ids
+func (bt BlockTimesteps) Active(s int, bodies []*Body, numBodies int, ids []int) []int {
+	for i := 0; i < numBodies; i++ {
+		if bt.starts(bodies[i].level, s) {
+			ids = append(ids, i)
+		}
+	}
+	return ids
+}
+
+// assign a level to an active body from its last computed acceleration,
+// a body only moves to a longer step at substeps where that step begins
+func (bt BlockTimesteps) AssignLevel(id int, bodies []*Body, s int) {
+	b := bodies[id]
+	acc := float32(math.Sqrt(float64(b.ax*b.ax + b.ay*b.ay + b.az*b.az)))
+	dt := bt.Controller.Timestep(acc)
+
+	level := 0
+	for level < bt.Levels && bt.LevelDt(level) > dt {
+		level++
+	}
+	for !bt.starts(level, s) {
+		level++
+	}
+	b.level = level
+}
+
+// Level returns the block timestep level of a body
+func (b *Body) Level() int {
+	return b.level
+}
@@ -0,0 +1,76 @@
+package nbody
+
+import (
+	"reflect"
+	"testing"
+)
+
+// block steps of 1, 1/2, 1/4 and 1/8 with dt = sqrt(1 / |a|)
+func testBlocks() BlockTimesteps {
+	return BlockTimesteps{
+		Controller: TimestepController{Eta: 1, Softening: 1, DtMin: 1.0 / 1024, DtMax: 1},
+		Levels:     3,
+	}
+}
+
+func TestBlockLevelDt(t *testing.T) {
+	bt := testBlocks()
+	if bt.Substeps() != 8 || bt.SubstepDt() != 0.125 {
+		t.Fatalf("%d substeps of %g", bt.Substeps(), bt.SubstepDt())
+	}
+	for level, want := range []float32{1, 0.5, 0.25, 0.125} {
+		if dt := bt.LevelDt(level); dt != want {
+			t.Errorf("level %d has dt %g, want %g", level, dt, want)
+		}
+	}
+}
+
+func TestAssignLevel(t *testing.T) {
+	tests := []struct {
+		acceleration float32
+		substep      int
+		want         int
+	}{
+		{1, 0, 0},   // dt 1
+		{4, 0, 1},   // dt 1/2
+		{10, 0, 2},  // dt 0.32 NEEDS THE SHORTER 1/4
+		{1e4, 0, 3}, // dt 1/100 IS CLAMPED TO THE DEEPEST LEVEL
+		// A LONG STEP ONLY STARTS WHERE IT BEGINS, THE BODY WAITS ON A SHORTER ONE
+		{1, 4, 1},
+		{1, 2, 2},
+		{1, 3, 3},
+		{4, 6, 2},
+	}
+	for _, test := range tests {
+		bodies := []*Body{NewBodyFromState(BodyState{AX: test.acceleration, Level: 3})}
+		testBlocks().AssignLevel(0, bodies, test.substep)
+		if level := bodies[0].Level(); level != test.want {
+			t.Errorf("acceleration %g at substep %d: level %d, want %d",
+				test.acceleration, test.substep, level, test.want)
+		}
+	}
+}
+
+// a body is active at the substeps where a step of its level begins
+func TestBlockActive(t *testing.T) {
+	bt := testBlocks()
+	var bodies []*Body
+	for level := 0; level <= bt.Levels; level++ {
+		bodies = append(bodies, NewBodyFromState(BodyState{Level: level}))
+	}
+	want := [][]int{
+		{0, 1, 2, 3},
+		{3},
+		{2, 3},
+		{3},
+		{1, 2, 3},
+		{3},
+		{2, 3},
+		{3},
+	}
+	for s := 0; s < bt.Substeps(); s++ {
+		if active := bt.Active(s, bodies, len(bodies), nil); !reflect.DeepEqual(active, want[s]) {
+			t.Errorf("substep %d: active %v, want %v", s, active, want[s])
+		}
+	}
+}
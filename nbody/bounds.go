@@ -0,0 +1,56 @@
+package nbody
+
+import "math"
+
+// BoundingBox is the smallest axis aligned box containing a set of bodies,
+// Min is larger than Max for the empty set
+type BoundingBox struct {
+	Min, Max [3]float32
+}
+
+// return the bounding box of no bodies, the identity of Union
+func EmptyBoundingBox() BoundingBox {
+	inf := float32(math.Inf(1))
+	return BoundingBox{Min: [3]float32{inf, inf, inf}, Max: [3]float32{-inf, -inf, -inf}}
+}
+
+// return the bounding box of the bodies [start, end)
+func Bounds(bodies []*Body, start, end int) BoundingBox {
+	box := EmptyBoundingBox()
+	for i := start; i < end; i++ {
+		position := [3]float32{bodies[i].x, bodies[i].y, bodies[i].z}
+		for c := range position {
+			if position[c] < box.Min[c] {
+				box.Min[c] = position[c]
+			}
+			if position[c] > box.Max[c] {
+				box.Max[c] = position[c]
+			}
+		}
+	}
+	return box
+}
+
+// Union returns the bounding box of the bodies of both boxes
+func (b BoundingBox) Union(other BoundingBox) BoundingBox {
+	for c := range b.Min {
+		if other.Min[c] < b.Min[c] {
+			b.Min[c] = other.Min[c]
+		}
+		if other.Max[c] > b.Max[c] {
+			b.Max[c] = other.Max[c]
+		}
+	}
+	return b
+}
+
+// Extent returns the longest side of the box, 0 if it is empty
+func (b BoundingBox) Extent() float32 {
+	var extent float32
+	for c := range b.Min {
+		if b.Max[c]-b.Min[c] > extent {
+			extent = b.Max[c] - b.Min[c]
+		}
+	}
+	return extent
+}
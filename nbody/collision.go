@@ -0,0 +1,153 @@
+package nbody
+
+import (
+	"math"
+	"sort"
+)
+
+// Collision records one collision between two bodies
+type Collision struct {
+	Kind       string  // "merge" or "bounce"
+	A, B       int     // Ids of the colliding bodies, A survives a merge
+	Mass       float32 // Mass of A after the collision
+	X, Y, Z    float32 // Position of A after the collision
+	VX, VY, VZ float32 // Velocity of A after the collision
+}
+
+// ID returns the stable id of a body
+func (b *Body) ID() int {
+	return b.id
+}
+
+// Radius returns the collision radius of a body
+func (b *Body) Radius() float32 {
+	return b.radius
+}
+
+// set the collision radius of a body
+func SetRadius(id int, bodies []*Body, radius float32) {
+	bodies[id].radius = radius
+}
+
+// append the pairs (id, j), j > id, of overlapping bodies to pairs
+func FindBodyCollisions(id int, bodies []*Body, numBodies int, box Box, pairs [][2]int) [][2]int {
+	for j := id + 1; j < numBodies; j++ {
+		dx := box.MinimumImage(bodies[j].x - bodies[id].x)
+		dy := box.MinimumImage(bodies[j].y - bodies[id].y)
+		dz := box.MinimumImage(bodies[j].z - bodies[id].z)
+
+		reach := bodies[id].radius + bodies[j].radius
+		if dx*dx+dy*dy+dz*dz < reach*reach {
+			pairs = append(pairs, [2]int{id, j})
+		}
+	}
+	return pairs
+}
+
+// sort pairs so merges are resolved in the same order however they were found
+func sortPairs(pairs [][2]int) {
+	sort.Slice(pairs, func(a, b int) bool {
+		if pairs[a][0] != pairs[b][0] {
+			return pairs[a][0] < pairs[b][0]
+		}
+		return pairs[a][1] < pairs[b][1]
+	})
+}
+
+// MergeCollisions merges every overlapping pair (indexes into bodies) into
+// the body with the lower index, conserving mass, momentum, charge and the
+// total volume of the radii. Merged bodies are removed, the remaining bodies
+// keep their order and ids. It returns the compacted bodies and the
+// collisions.
+func MergeCollisions(bodies []*Body, numBodies int, pairs [][2]int, box Box) ([]*Body, []Collision) {
+	if len(pairs) == 0 {
+		return bodies[:numBodies], nil
+	}
+	sortPairs(pairs)
+
+	// A BODY MERGED EARLIER IN THIS STEP IS REPLACED BY ITS SURVIVOR
+	survivor := make(map[int]int)
+	find := func(i int) int {
+		for {
+			s, ok := survivor[i]
+			if !ok {
+				return i
+			}
+			i = s
+		}
+	}
+
+	var events []Collision
+	for _, pair := range pairs {
+		i, j := find(pair[0]), find(pair[1])
+		if i == j {
+			continue
+		}
+		if j < i {
+			i, j = j, i
+		}
+		a, b := bodies[i], bodies[j]
+
+		// CENTER OF MASS, MEASURED FROM a SO IT WORKS ACROSS A PERIODIC BOUNDARY
+		mass := a.mass + b.mass
+		a.x = box.Wrap(a.x + b.mass*box.MinimumImage(b.x-a.x)/mass)
+		a.y = box.Wrap(a.y + b.mass*box.MinimumImage(b.y-a.y)/mass)
+		a.z = box.Wrap(a.z + b.mass*box.MinimumImage(b.z-a.z)/mass)
+		a.vx = (a.mass*a.vx + b.mass*b.vx) / mass
+		a.vy = (a.mass*a.vy + b.mass*b.vy) / mass
+		a.vz = (a.mass*a.vz + b.mass*b.vz) / mass
+		a.radius = float32(math.Cbrt(float64(a.radius*a.radius*a.radius + b.radius*b.radius*b.radius)))
+		if b.level > a.level {
+			a.level = b.level
+		}
+		a.mass = mass
+		a.charge += b.charge
+
+		survivor[j] = i
+		events = append(events, Collision{"merge", a.id, b.id, a.mass, a.x, a.y, a.z, a.vx, a.vy, a.vz})
+	}
+
+	// COMPACT THE SURVIVORS
+	n := 0
+	for i := 0; i < numBodies; i++ {
+		if _, merged := survivor[i]; !merged {
+			bodies[n] = bodies[i]
+			n++
+		}
+	}
+	for i := n; i < numBodies; i++ {
+		bodies[i] = nil
+	}
+	return bodies[:n], events
+}
+
+// BounceCollisions reflects the velocities of every approaching overlapping
+// pair along the line of centers, a perfectly elastic collision
+func BounceCollisions(bodies []*Body, pairs [][2]int, box Box) []Collision {
+	sortPairs(pairs)
+
+	var events []Collision
+	for _, pair := range pairs {
+		a, b := bodies[pair[0]], bodies[pair[1]]
+		nx, ny, nz := box.MinimumImage(b.x-a.x), box.MinimumImage(b.y-a.y), box.MinimumImage(b.z-a.z)
+		dist := float32(math.Sqrt(float64(nx*nx + ny*ny + nz*nz)))
+		if dist == 0 {
+			continue
+		}
+		nx, ny, nz = nx/dist, ny/dist, nz/dist
+
+		// ONLY BODIES MOVING TOWARDS EACH OTHER BOUNCE
+		approach := (a.vx-b.vx)*nx + (a.vy-b.vy)*ny + (a.vz-b.vz)*nz
+		if approach <= 0 {
+			continue
+		}
+
+		mass := a.mass + b.mass
+		ka, kb := 2*b.mass/mass*approach, 2*a.mass/mass*approach
+		a.vx, a.vy, a.vz = a.vx-ka*nx, a.vy-ka*ny, a.vz-ka*nz
+		b.vx, b.vy, b.vz = b.vx+kb*nx, b.vy+kb*ny, b.vz+kb*nz
+
+		events = append(events, Collision{"bounce", a.id, b.id, a.mass, a.x, a.y, a.z, a.vx, a.vy, a.vz})
+	}
+	return events
+}
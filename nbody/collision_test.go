@@ -0,0 +1,149 @@
+package nbody
+
+import (
+	"math"
+	"testing"
+)
+
+// total mass, momentum and kinetic energy of bodies
+func totals(bodies []*Body) (mass float64, momentum [3]float64, kinetic float64) {
+	for _, b := range bodies {
+		m := float64(b.mass)
+		mass += m
+		momentum[0] += m * float64(b.vx)
+		momentum[1] += m * float64(b.vy)
+		momentum[2] += m * float64(b.vz)
+		kinetic += m * float64(b.vx*b.vx+b.vy*b.vy+b.vz*b.vz) / 2
+	}
+	return mass, momentum, kinetic
+}
+
+func close32(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-5*math.Max(1, math.Abs(b))
+}
+
+// return the bodies of the states, every one of radius 0.5
+func collisionBodies(states ...BodyState) []*Body {
+	bodies := make([]*Body, len(states))
+	for i, state := range states {
+		state.Radius = 0.5
+		bodies[i] = NewBodyFromState(state)
+	}
+	return bodies
+}
+
+var collisionTests = []struct {
+	name   string
+	box    Box
+	bodies []BodyState
+	pairs  int // Overlapping pairs
+}{
+	{"head on", Box{}, []BodyState{
+		{ID: 3, X: 0, VX: 1, Mass: 2},
+		{ID: 5, X: 0.8, VX: -1, Mass: 1},
+	}, 1},
+	{"oblique", Box{}, []BodyState{
+		{ID: 1, X: 0, Y: 0, Z: 0, VX: 1, VY: 0.5, VZ: -0.25, Mass: 3},
+		{ID: 2, X: 0.3, Y: 0.4, Z: 0.5, VX: -0.5, VY: -1, VZ: 0, Mass: 0.5},
+	}, 1},
+	{"chain", Box{}, []BodyState{
+		{ID: 0, X: 0, VX: 1, Mass: 1},
+		{ID: 4, X: 0.9, VY: 1, Mass: 2},
+		{ID: 8, X: 1.8, VX: -2, Mass: 1},
+	}, 2},
+	{"across the boundary", Box{Size: 10}, []BodyState{
+		{ID: 6, X: 4.9, VX: 1, Mass: 1},
+		{ID: 7, X: -4.9, VX: -1, Mass: 1},
+	}, 1},
+}
+
+// return the bodies of a test and their overlapping pairs
+func collidingBodies(t *testing.T, states []BodyState, box Box, want int) ([]*Body, [][2]int) {
+	bodies := collisionBodies(states...)
+	var pairs [][2]int
+	for i := range bodies {
+		pairs = FindBodyCollisions(i, bodies, len(bodies), box, pairs)
+	}
+	if len(pairs) != want {
+		t.Fatalf("found the pairs %v, want %d", pairs, want)
+	}
+	return bodies, pairs
+}
+
+// a merge conserves mass and momentum and keeps the body of lowest index,
+// at the center of mass
+func TestMergeCollisions(t *testing.T) {
+	for _, test := range collisionTests {
+		t.Run(test.name, func(t *testing.T) {
+			bodies, pairs := collidingBodies(t, test.bodies, test.box, test.pairs)
+			mass, momentum, _ := totals(bodies)
+			var center float64
+			for _, b := range bodies {
+				center += float64(b.mass * b.y)
+			}
+
+			merged, events := MergeCollisions(bodies, len(bodies), pairs, test.box)
+			if len(merged) != 1 || len(events) != len(pairs) {
+				t.Fatalf("%d bodies and %d events left", len(merged), len(events))
+			}
+			if merged[0].ID() != test.bodies[0].ID {
+				t.Fatalf("body %d survived, want %d", merged[0].ID(), test.bodies[0].ID)
+			}
+			mergedMass, mergedMomentum, _ := totals(merged)
+			if !close32(mergedMass, mass) {
+				t.Errorf("mass %g, want %g", mergedMass, mass)
+			}
+			for c := range momentum {
+				if !close32(mergedMomentum[c], momentum[c]) {
+					t.Errorf("momentum %v, want %v", mergedMomentum, momentum)
+				}
+			}
+			if !close32(float64(merged[0].y), center/mass) {
+				t.Errorf("merged at y = %g, want the center of mass %g", merged[0].y, center/mass)
+			}
+		})
+	}
+}
+
+// the merge of a pair across the boundary lands on the boundary, not in
+// the middle of the box
+func TestMergeAcrossBoundary(t *testing.T) {
+	test := collisionTests[3]
+	bodies, pairs := collidingBodies(t, test.bodies, test.box, 1)
+	merged, _ := MergeCollisions(bodies, len(bodies), pairs, test.box)
+	if x := merged[0].x; math.Abs(math.Abs(float64(x))-5) > 1e-5 {
+		t.Fatalf("merged at x = %g, want the boundary", x)
+	}
+}
+
+// an elastic bounce conserves momentum and kinetic energy and turns the
+// approach into a separation
+func TestBounceCollisions(t *testing.T) {
+	for _, test := range collisionTests {
+		if test.pairs != 1 {
+			continue
+		}
+		t.Run(test.name, func(t *testing.T) {
+			bodies, pairs := collidingBodies(t, test.bodies, test.box, test.pairs)
+			_, momentum, kinetic := totals(bodies)
+
+			if events := BounceCollisions(bodies, pairs, test.box); len(events) != 1 {
+				t.Fatalf("%d bounces, want 1", len(events))
+			}
+			_, bouncedMomentum, bouncedKinetic := totals(bodies)
+			for c := range momentum {
+				if !close32(bouncedMomentum[c], momentum[c]) {
+					t.Errorf("momentum %v, want %v", bouncedMomentum, momentum)
+				}
+			}
+			if !close32(bouncedKinetic, kinetic) {
+				t.Errorf("kinetic energy %g, want %g", bouncedKinetic, kinetic)
+			}
+
+			// A SECOND BOUNCE OF THE SEPARATING PAIR DOES NOTHING
+			if events := BounceCollisions(bodies, pairs, test.box); len(events) != 0 {
+				t.Errorf("the separating pair bounced again")
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package nbody
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Column sets accepted by NewCSVWriter
+const (
+	ColumnsPositions = "positions" // step, x, y, z
+	ColumnsState     = "state"     // step, time, id, x, y, z, vx, vy, vz, mass
+	ColumnsFull      = "full"      // state + speed, ax, ay, az, acc
+)
+
+var columnSets = map[string][]string{
+	ColumnsPositions: {"step", "x", "y", "z"},
+	ColumnsState:     {"step", "time", "id", "x", "y", "z", "vx", "vy", "vz", "mass"},
+	ColumnsFull: {"step", "time", "id", "x", "y", "z", "vx", "vy", "vz", "mass",
+		"speed", "ax", "ay", "az", "acc"},
+}
+
+// per body quantities that can be written as a column
+var bodyColumns = map[string]func(b *Body) float32{
+	"x":      func(b *Body) float32 { return b.x },
+	"y":      func(b *Body) float32 { return b.y },
+	"z":      func(b *Body) float32 { return b.z },
+	"vx":     func(b *Body) float32 { return b.vx },
+	"vy":     func(b *Body) float32 { return b.vy },
+	"vz":     func(b *Body) float32 { return b.vz },
+	"mass":   func(b *Body) float32 { return b.mass },
+	"radius": func(b *Body) float32 { return b.radius },
+	"charge": func(b *Body) float32 { return b.charge },
+	"ax":     func(b *Body) float32 { return b.ax },
+	"ay":     func(b *Body) float32 { return b.ay },
+	"az":     func(b *Body) float32 { return b.az },
+	"speed": func(b *Body) float32 {
+		return float32(math.Sqrt(float64(b.vx*b.vx + b.vy*b.vy + b.vz*b.vz)))
+	},
+	"acc": func(b *Body) float32 {
+		return float32(math.Sqrt(float64(b.ax*b.ax + b.ay*b.ay + b.az*b.az)))
+	},
+}
+
+// ParseColumns resolves a column set name ("positions", "state", "full")
+// or a comma separated list of column names
+func ParseColumns(spec string) ([]string, error) {
+	if columns, ok := columnSets[spec]; ok {
+		return columns, nil
+	}
+
+	columns := strings.Split(spec, ",")
+	for i, column := range columns {
+		columns[i] = strings.TrimSpace(column)
+		switch columns[i] {
+		case "step", "time", "id":
+		default:
+			if _, ok := bodyColumns[columns[i]]; !ok {
+				return nil, fmt.Errorf("unknown csv column %q", columns[i])
+			}
+		}
+	}
+	return columns, nil
+}
+
+// CSVWriter writes snapshots of the bodies as csv rows, one row per body,
+// preceded by a single header row
+type CSVWriter struct {
+	w           io.Writer
+	columns     []string
+	precision   int
+	wroteHeader bool
+	row         []byte
+}
+
+// return a new csv writer for the given column spec (see ParseColumns)
+// floats are written in %e format with precision digits after the point
+func NewCSVWriter(w io.Writer, spec string, precision int) (*CSVWriter, error) {
+	columns, err := ParseColumns(spec)
+	if err != nil {
+		return nil, err
+	}
+	if precision < 0 {
+		return nil, fmt.Errorf("invalid csv precision %d", precision)
+	}
+	return &CSVWriter{w: w, columns: columns, precision: precision}, nil
+}
+
+// Columns returns the names of the columns written by the writer
+func (cw *CSVWriter) Columns() []string {
+	return cw.columns
+}
+
+// write the positions of the first numBodies bodies at the given step
+func (cw *CSVWriter) WriteFrame(step int, time float32, bodies []*Body, numBodies int) error {
+	if !cw.wroteHeader {
+		if _, err := io.WriteString(cw.w, strings.Join(cw.columns, ", ")+"\n"); err != nil {
+			return err
+		}
+		cw.wroteHeader = true
+	}
+
+	for i := 0; i < numBodies; i++ {
+		row := cw.row[:0]
+		for c, column := range cw.columns {
+			if c > 0 {
+				row = append(row, ", "...)
+			}
+			switch column {
+			case "step":
+				row = strconv.AppendInt(row, int64(step), 10)
+			case "time":
+				row = strconv.AppendFloat(row, float64(time), 'e', cw.precision, 32)
+			case "id":
+				row = strconv.AppendInt(row, int64(bodies[i].id), 10)
+			default:
+				row = strconv.AppendFloat(row, float64(bodyColumns[column](bodies[i])), 'e', cw.precision, 32)
+			}
+		}
+		row = append(row, '\n')
+		cw.row = row
+
+		if _, err := cw.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Value returns the named per body quantity (see ParseColumns) of a body,
+// ids are returned as floats
+func (b *Body) Value(column string) (float32, bool) {
+	if column == "id" {
+		return float32(b.id), true
+	}
+	value, ok := bodyColumns[column]
+	if !ok {
+		return 0, false
+	}
+	return value(b), true
+}
@@ -0,0 +1,83 @@
+package nbody
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// return two bodies with distinct values in every column
+func csvBodies() []*Body {
+	return []*Body{
+		NewBodyFromState(BodyState{ID: 7, X: 1, Y: 2, Z: 3, VX: 3, VY: 4, VZ: 0, Mass: 2}),
+		NewBodyFromState(BodyState{ID: 9, X: -1, Y: 0.5, Z: 0, VX: 0, VY: 0, VZ: -1, Mass: 1}),
+	}
+}
+
+func TestCSVWriterColumns(t *testing.T) {
+	tests := []struct {
+		spec      string
+		precision int
+		want      []string
+	}{
+		{ColumnsPositions, 2, []string{
+			"step, x, y, z",
+			"5, 1.00e+00, 2.00e+00, 3.00e+00",
+			"5, -1.00e+00, 5.00e-01, 0.00e+00",
+		}},
+		{ColumnsState, 1, []string{
+			"step, time, id, x, y, z, vx, vy, vz, mass",
+			"5, 2.5e-01, 7, 1.0e+00, 2.0e+00, 3.0e+00, 3.0e+00, 4.0e+00, 0.0e+00, 2.0e+00",
+			"5, 2.5e-01, 9, -1.0e+00, 5.0e-01, 0.0e+00, 0.0e+00, 0.0e+00, -1.0e+00, 1.0e+00",
+		}},
+		{"id, speed", 3, []string{
+			"id, speed",
+			"7, 5.000e+00",
+			"9, 1.000e+00",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			var out bytes.Buffer
+			cw, err := NewCSVWriter(&out, test.spec, test.precision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bodies := csvBodies()
+			if err := cw.WriteFrame(5, 0.25, bodies, len(bodies)); err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.Join(test.want, "\n") + "\n"; out.String() != want {
+				t.Fatalf("wrote\n%swant\n%s", out.String(), want)
+			}
+		})
+	}
+}
+
+// the header is written once, before the first frame
+func TestCSVWriterHeaderOnce(t *testing.T) {
+	var out bytes.Buffer
+	cw, err := NewCSVWriter(&out, "step, id", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := csvBodies()
+	for step := 0; step < 3; step++ {
+		if err := cw.WriteFrame(step, 0, bodies, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := "step, id\n0, 7\n1, 7\n2, 7\n"; out.String() != want {
+		t.Fatalf("wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestCSVWriterErrors(t *testing.T) {
+	if _, err := NewCSVWriter(&bytes.Buffer{}, "x, colour", 2); err == nil {
+		t.Error("an unknown column was accepted")
+	}
+	if _, err := NewCSVWriter(&bytes.Buffer{}, ColumnsFull, -1); err == nil {
+		t.Error("a negative precision was accepted")
+	}
+}
@@ -0,0 +1,58 @@
+package nbody
+
+// kinetic energy of the first numBodies bodies
+func KineticEnergy(bodies []*Body, numBodies int) float64 {
+	var energy float64
+	for i := 0; i < numBodies; i++ {
+		b := bodies[i]
+		energy += 0.5 * float64(b.mass) * float64(b.vx*b.vx+b.vy*b.vy+b.vz*b.vz)
+	}
+	return energy
+}
+
+// potential energy of the pairs (id, j) with j > id, consistent with the
+// forces of ComputeBodyForce or of the force law. In a periodic box with Ewald corrections it
+// also includes the energy of the body with its own images, and with an
+// external potential the energy of the body in it.
+func BodyPotentialEnergy(id int, bodies []*Body, numBodies int, physics *Physics) float64 {
+	var energy float64
+	mass := float64(bodies[id].mass)
+	for j := id + 1; j < numBodies; j++ {
+		dx, dy, dz := physics.separation(bodies[id], bodies[j])
+		r2 := float64(dx)*float64(dx) + float64(dy)*float64(dy) + float64(dz)*float64(dz)
+
+		if physics.Law != nil {
+			energy += physics.Law.PairPotential(bodies[id], bodies[j], r2)
+			continue
+		}
+		potential := physics.Kernel.Potential(r2)
+		if physics.Ewald != nil {
+			_, _, _, correction := physics.Ewald.Correction(dx, dy, dz)
+			potential += correction
+		}
+		energy += mass * float64(bodies[j].mass) * potential
+	}
+
+	if physics.Ewald != nil && physics.Law == nil {
+		_, _, _, self := physics.Ewald.Correction(0, 0, 0)
+		energy += 0.5 * mass * mass * self
+	}
+	if physics.External != nil {
+		energy += mass * physics.External.Potential(bodies[id].x, bodies[id].y, bodies[id].z)
+	}
+	return energy
+}
+
+// potential energy of the first numBodies bodies
+func PotentialEnergy(bodies []*Body, numBodies int, physics *Physics) float64 {
+	var energy float64
+	for i := 0; i < numBodies; i++ {
+		energy += BodyPotentialEnergy(i, bodies, numBodies, physics)
+	}
+	return energy
+}
+
+// total energy of the first numBodies bodies
+func TotalEnergy(bodies []*Body, numBodies int, physics *Physics) float64 {
+	return KineticEnergy(bodies, numBodies) + PotentialEnergy(bodies, numBodies, physics)
+}
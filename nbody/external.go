@@ -0,0 +1,239 @@
+package nbody
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ExternalPotential is an analytic potential that acts on every body in
+// addition to the forces between bodies, e.g. a host galaxy
+type ExternalPotential interface {
+	// Acceleration at a position
+	Acceleration(x, y, z float32) (ax, ay, az float32)
+	// Potential per unit mass at a position
+	Potential(x, y, z float32) float64
+}
+
+// PointMass is the softened potential of a fixed mass at Center
+type PointMass struct {
+	Mass      float64
+	Center    [3]float64
+	Softening float64
+}
+
+func (p PointMass) Acceleration(x, y, z float32) (ax, ay, az float32) {
+	d := offset(x, y, z, p.Center)
+	r2 := d[0]*d[0] + d[1]*d[1] + d[2]*d[2] + p.Softening*p.Softening
+	if r2 == 0 {
+		return 0, 0, 0
+	}
+	factor := -p.Mass / (r2 * math.Sqrt(r2))
+	return float32(factor * d[0]), float32(factor * d[1]), float32(factor * d[2])
+}
+
+func (p PointMass) Potential(x, y, z float32) float64 {
+	d := offset(x, y, z, p.Center)
+	r2 := d[0]*d[0] + d[1]*d[1] + d[2]*d[2] + p.Softening*p.Softening
+	if r2 == 0 {
+		return 0
+	}
+	return -p.Mass / math.Sqrt(r2)
+}
+
+// NFW is the Navarro-Frenk-White dark matter halo with characteristic mass
+// Mass = 4π ρ0 Scale³ and scale radius Scale
+type NFW struct {
+	Mass   float64
+	Scale  float64
+	Center [3]float64
+}
+
+func (h NFW) Acceleration(x, y, z float32) (ax, ay, az float32) {
+	d := offset(x, y, z, h.Center)
+	r := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+	if r == 0 {
+		return 0, 0, 0
+	}
+	// MASS ENCLOSED WITHIN r
+	s := r / h.Scale
+	enclosed := h.Mass * (math.Log1p(s) - s/(1+s))
+	factor := -enclosed / (r * r * r)
+	return float32(factor * d[0]), float32(factor * d[1]), float32(factor * d[2])
+}
+
+func (h NFW) Potential(x, y, z float32) float64 {
+	d := offset(x, y, z, h.Center)
+	r := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+	if r == 0 {
+		return -h.Mass / h.Scale
+	}
+	return -h.Mass * math.Log1p(r/h.Scale) / r
+}
+
+// MiyamotoNagai is an axisymmetric disk in the xy plane with scale length
+// A and scale height B
+type MiyamotoNagai struct {
+	Mass   float64
+	A, B   float64
+	Center [3]float64
+}
+
+func (m MiyamotoNagai) Acceleration(x, y, z float32) (ax, ay, az float32) {
+	d := offset(x, y, z, m.Center)
+	zeta := math.Sqrt(d[2]*d[2] + m.B*m.B)
+	s := m.A + zeta
+	d2 := d[0]*d[0] + d[1]*d[1] + s*s
+	if d2 == 0 {
+		return 0, 0, 0
+	}
+	factor := -m.Mass / (d2 * math.Sqrt(d2))
+	// IN THE PLANE OF A DISK WITHOUT THICKNESS (B = 0) ONLY THE VERTICAL PULL CANCELS
+	if zeta == 0 {
+		return float32(factor * d[0]), float32(factor * d[1]), 0
+	}
+	return float32(factor * d[0]), float32(factor * d[1]), float32(factor * d[2] * s / zeta)
+}
+
+func (m MiyamotoNagai) Potential(x, y, z float32) float64 {
+	d := offset(x, y, z, m.Center)
+	s := m.A + math.Sqrt(d[2]*d[2]+m.B*m.B)
+	d2 := d[0]*d[0] + d[1]*d[1] + s*s
+	if d2 == 0 {
+		return 0
+	}
+	return -m.Mass / math.Sqrt(d2)
+}
+
+// UniformField is a constant acceleration, its potential is zero at the
+// origin
+type UniformField struct {
+	Field [3]float64
+}
+
+func (u UniformField) Acceleration(x, y, z float32) (ax, ay, az float32) {
+	return float32(u.Field[0]), float32(u.Field[1]), float32(u.Field[2])
+}
+
+func (u UniformField) Potential(x, y, z float32) float64 {
+	return -(u.Field[0]*float64(x) + u.Field[1]*float64(y) + u.Field[2]*float64(z))
+}
+
+// ExternalPotentials is the sum of several potentials
+type ExternalPotentials []ExternalPotential
+
+func (ps ExternalPotentials) Acceleration(x, y, z float32) (ax, ay, az float32) {
+	for _, p := range ps {
+		px, py, pz := p.Acceleration(x, y, z)
+		ax, ay, az = ax+px, ay+py, az+pz
+	}
+	return ax, ay, az
+}
+
+func (ps ExternalPotentials) Potential(x, y, z float32) float64 {
+	var potential float64
+	for _, p := range ps {
+		potential += p.Potential(x, y, z)
+	}
+	return potential
+}
+
+func offset(x, y, z float32, center [3]float64) [3]float64 {
+	return [3]float64{float64(x) - center[0], float64(y) - center[1], float64(z) - center[2]}
+}
+
+// add the external acceleration at the position of a body to its
+// acceleration
+func AddExternalAcceleration(id int, bodies []*Body, external ExternalPotential) {
+	ax, ay, az := external.Acceleration(bodies[id].x, bodies[id].y, bodies[id].z)
+	bodies[id].ax += ax
+	bodies[id].ay += ay
+	bodies[id].az += az
+}
+
+// ExternalSpec describes an external potential in configurations and
+// config files
+type ExternalSpec struct {
+	Kind   string     `json:"kind"` // "point", "nfw", "miyamoto-nagai" or "uniform"
+	Mass   float64    `json:"mass,omitempty"`
+	Center [3]float64 `json:"center,omitempty"`
+	// Softening of a point mass, scale radius of a halo or scale length of
+	// a disk
+	A     float64    `json:"a,omitempty"`
+	B     float64    `json:"b,omitempty"`     // Scale height of a disk
+	Field [3]float64 `json:"field,omitempty"` // Acceleration of a uniform field
+}
+
+// return the potential described by spec
+func NewExternalPotential(spec ExternalSpec) (ExternalPotential, error) {
+	switch spec.Kind {
+	case "point":
+		if spec.A < 0 {
+			return nil, fmt.Errorf("point mass softening must not be negative, got %g", spec.A)
+		}
+		return PointMass{Mass: spec.Mass, Center: spec.Center, Softening: spec.A}, nil
+	case "nfw":
+		if spec.A <= 0 {
+			return nil, fmt.Errorf("NFW scale radius must be positive, got %g", spec.A)
+		}
+		return NFW{Mass: spec.Mass, Scale: spec.A, Center: spec.Center}, nil
+	case "miyamoto-nagai":
+		if spec.A < 0 || spec.B < 0 || spec.A+spec.B == 0 {
+			return nil, fmt.Errorf("Miyamoto-Nagai scale lengths must not be negative and not both zero")
+		}
+		return MiyamotoNagai{Mass: spec.Mass, A: spec.A, B: spec.B, Center: spec.Center}, nil
+	case "uniform":
+		return UniformField{Field: spec.Field}, nil
+	}
+	return nil, fmt.Errorf("unknown external potential %q", spec.Kind)
+}
+
+// return the sum of the potentials described by specs, or nil if there
+// are none
+func NewExternalPotentials(specs []ExternalSpec) (ExternalPotential, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	potentials := make(ExternalPotentials, 0, len(specs))
+	for _, spec := range specs {
+		p, err := NewExternalPotential(spec)
+		if err != nil {
+			return nil, err
+		}
+		potentials = append(potentials, p)
+	}
+	return potentials, nil
+}
+
+// ParseExternalSpec parses the command line form of a potential,
+// "<kind>:<key>=<value>,..." with the keys mass, x, y, z, a, b, gx, gy and
+// gz, e.g. "nfw:mass=1e6,a=20"
+func ParseExternalSpec(s string) (ExternalSpec, error) {
+	kind, params := s, ""
+	if colon := strings.IndexByte(s, ':'); colon >= 0 {
+		kind, params = s[:colon], s[colon+1:]
+	}
+	spec := ExternalSpec{Kind: strings.TrimSpace(kind)}
+	fields := map[string]*float64{
+		"mass": &spec.Mass, "x": &spec.Center[0], "y": &spec.Center[1], "z": &spec.Center[2],
+		"a": &spec.A, "b": &spec.B, "gx": &spec.Field[0], "gy": &spec.Field[1], "gz": &spec.Field[2],
+	}
+	for _, param := range strings.Split(params, ",") {
+		if strings.TrimSpace(param) == "" {
+			continue
+		}
+		kv := strings.SplitN(param, "=", 2)
+		field, ok := fields[strings.TrimSpace(kv[0])]
+		if !ok || len(kv) != 2 {
+			return spec, fmt.Errorf("invalid external potential parameter %q", param)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return spec, err
+		}
+		*field = value
+	}
+	_, err := NewExternalPotential(spec)
+	return spec, err
+}
@@ -0,0 +1,63 @@
+package nbody
+
+import (
+	"math"
+	"testing"
+)
+
+var externalTests = []struct {
+	name      string
+	potential ExternalPotential
+}{
+	{"point", PointMass{Mass: 5, Center: [3]float64{1, -1, 0.5}, Softening: 0.2}},
+	{"nfw", NFW{Mass: 100, Scale: 3, Center: [3]float64{0, 0, 1}}},
+	{"miyamoto-nagai", MiyamotoNagai{Mass: 50, A: 2, B: 0.5}},
+	{"thin disk", MiyamotoNagai{Mass: 50, A: 2}},
+	{"uniform", UniformField{Field: [3]float64{0.5, -1, 2}}},
+	{"sum", ExternalPotentials{NFW{Mass: 100, Scale: 3}, UniformField{Field: [3]float64{0, 0, -1}}}},
+}
+
+// the acceleration of every potential is minus its gradient
+func TestExternalAccelerationIsGradient(t *testing.T) {
+	positions := [][3]float32{{2, 1, 0.7}, {-3, 0.5, -1.5}, {0.3, -4, 2.2}}
+	for _, test := range externalTests {
+		for _, p := range positions {
+			ax, ay, az := test.potential.Acceleration(p[0], p[1], p[2])
+			for c, a := range [3]float32{ax, ay, az} {
+				plus, minus := p, p
+				plus[c] += 1e-2
+				minus[c] -= 1e-2
+				h := float64(plus[c] - minus[c])
+				gradient := (test.potential.Potential(plus[0], plus[1], plus[2]) -
+					test.potential.Potential(minus[0], minus[1], minus[2])) / h
+				if math.Abs(float64(a)+gradient) > 1e-3*math.Max(1, math.Abs(gradient)) {
+					t.Errorf("%s at %v: acceleration %g along %d, minus the gradient %g", test.name, p, a, c, -gradient)
+				}
+			}
+		}
+	}
+}
+
+// a disk without thickness still pulls bodies in its plane towards its
+// center
+func TestMiyamotoNagaiThinDiskPlane(t *testing.T) {
+	disk := MiyamotoNagai{Mass: 50, A: 2}
+	ax, ay, az := disk.Acceleration(3, -4, 0)
+	// |a| = M r / (r² + A²)^3/2 TOWARDS THE CENTER
+	want := 50 * 5 / math.Pow(25+4, 1.5)
+	if math.Abs(float64(ax)+want*3/5) > 1e-5 || math.Abs(float64(ay)-want*4/5) > 1e-5 || az != 0 {
+		t.Fatalf("acceleration (%g, %g, %g), want (%g, %g, 0)", ax, ay, az, -want*3/5, want*4/5)
+	}
+}
+
+func TestParseExternalSpec(t *testing.T) {
+	spec, err := ParseExternalSpec("nfw: mass=1e6, a=20, z=-3")
+	if err != nil || spec.Kind != "nfw" || spec.Mass != 1e6 || spec.A != 20 || spec.Center[2] != -3 {
+		t.Fatalf("parsed %+v, err %v", spec, err)
+	}
+	for _, bad := range []string{"halo:mass=1", "nfw:mass=1", "point:r=1", "point:mass=x", "miyamoto-nagai:mass=1"} {
+		if _, err := ParseExternalSpec(bad); err == nil {
+			t.Errorf("%q was accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package nbody
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// fftPlan holds the twiddle factors and bit reversal permutation of an
+// iterative radix-2 FFT of a fixed power-of-two length
+type fftPlan struct {
+	n       int
+	twiddle []complex128 // exp(-2πik/n) for k < n/2
+	reverse []int
+}
+
+// return the plan of an FFT of length n, n must be a power of two
+func newFFTPlan(n int) (*fftPlan, error) {
+	if n < 2 || n&(n-1) != 0 {
+		return nil, fmt.Errorf("nbody: FFT length %d is not a power of two", n)
+	}
+
+	p := &fftPlan{n: n, twiddle: make([]complex128, n/2), reverse: make([]int, n)}
+	for k := range p.twiddle {
+		sin, cos := math.Sincos(-2 * math.Pi * float64(k) / float64(n))
+		p.twiddle[k] = complex(cos, sin)
+	}
+	shift := 64 - uint(bits.TrailingZeros(uint(n)))
+	for i := range p.reverse {
+		p.reverse[i] = int(bits.Reverse64(uint64(i)) >> shift)
+	}
+	return p, nil
+}
+
+// transform a in place, the inverse transform is not normalized
+func (p *fftPlan) transform(a []complex128, inverse bool) {
+	for i, j := range p.reverse {
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+
+	for size := 2; size <= p.n; size <<= 1 {
+		half, step := size/2, p.n/size
+		for start := 0; start < p.n; start += size {
+			for k := 0; k < half; k++ {
+				w := p.twiddle[k*step]
+				if inverse {
+					w = complex(real(w), -imag(w))
+				}
+				u, v := a[start+k], w*a[start+k+half]
+				a[start+k], a[start+k+half] = u+v, u-v
+			}
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package nbody
+
+import (
+	"fmt"
+	"math"
+)
+
+// FMM computes accelerations with the fast multipole method on an octree
+// using Cartesian Taylor expansions: every cell gets a multipole expansion
+// of its bodies about its center of mass (upward pass), well separated
+// cells interact through their expansions, which are translated into local
+// expansions, and nearby leaves interact directly with the softening kernel
+// (downward pass). Expansions are truncated at a total order Order, so the
+// error falls roughly as Theta^(Order+1).
+//
+// The upward pass works level by level and the downward pass on
+// independent branches of the tree so both can be split into tasks.
+type FMM struct {
+	Order    int     // Order of the expansions
+	Theta    float64 // Opening angle, cells interact through expansions if (rA + rB) < Theta * distance
+	LeafSize int     // Largest number of bodies in a leaf
+
+	terms [][3]int // Multi-indices (t, u, v) with t+u+v <= Order, by total order
+	index []int    // Term of (t, u, v), (Order+1)³ entries
+
+	physics  *Physics
+	near     float64 // Cells closer than this interact directly so the softening applies
+	bodies   []*Body
+	order    []int // Bodies sorted by cell
+	cells    []fmmCell
+	levels   [][]int
+	branches []fmmBranch
+}
+
+type fmmCell struct {
+	center     [3]float64 // Center of mass, the center of both expansions
+	radius     float64    // Distance from the center to the farthest body
+	mass       float64
+	start, end int   // Bodies order[start:end]
+	children   []int // Nil for leaves
+	multipole  []float64
+	local      []float64
+}
+
+// a cell of the downward pass with the source cells it still has to visit
+type fmmBranch struct {
+	cell    int
+	sources []int
+}
+
+// return a fast multipole solver of the given order (at least 1), opening
+// angle and leaf size
+func NewFMM(order int, theta float64, leafSize int) (*FMM, error) {
+	if order < 1 || order > 12 {
+		return nil, fmt.Errorf("nbody: FMM order must be between 1 and 12, got %d", order)
+	}
+	if theta <= 0 || theta >= 1 {
+		return nil, fmt.Errorf("nbody: FMM opening angle must be in (0, 1), got %g", theta)
+	}
+	if leafSize < 1 {
+		return nil, fmt.Errorf("nbody: FMM leaf size must be positive, got %d", leafSize)
+	}
+
+	f := &FMM{Order: order, Theta: theta, LeafSize: leafSize}
+	n := order + 1
+	f.index = make([]int, n*n*n)
+	for s := 0; s <= order; s++ {
+		for t := s; t >= 0; t-- {
+			for u := s - t; u >= 0; u-- {
+				v := s - t - u
+				f.index[(t*n+u)*n+v] = len(f.terms)
+				f.terms = append(f.terms, [3]int{t, u, v})
+			}
+		}
+	}
+	return f, nil
+}
+
+func (f *FMM) term(t, u, v int) int {
+	n := f.Order + 1
+	return f.index[(t*n+u)*n+v]
+}
+
+// powers d^t/t! of every component of d up to the order
+func (f *FMM) powers(d [3]float64) [3][]float64 {
+	var p [3][]float64
+	for c := range p {
+		p[c] = make([]float64, f.Order+1)
+		p[c][0] = 1
+		for k := 1; k <= f.Order; k++ {
+			p[c][k] = p[c][k-1] * d[c] / float64(k)
+		}
+	}
+	return p
+}
+
+// Build sorts the bodies into an octree, physics softens the direct
+// interactions of nearby leaves and must be open space
+func (f *FMM) Build(bodies []*Body, numBodies int, physics *Physics) error {
+	if physics.Box.Periodic() {
+		return fmt.Errorf("nbody: the FMM solver does not support periodic boxes")
+	}
+	f.physics, f.bodies = physics, bodies
+	f.near = 3 * float64(physics.Kernel.Length())
+	f.order = f.order[:0]
+	for i := 0; i < numBodies; i++ {
+		f.order = append(f.order, i)
+	}
+	f.cells, f.levels, f.branches = f.cells[:0], f.levels[:0], f.branches[:0]
+	if numBodies == 0 {
+		return nil
+	}
+
+	// BOUNDING CUBE OF THE BODIES
+	low := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	high := [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for i := 0; i < numBodies; i++ {
+		for c, x := range [3]float32{bodies[i].x, bodies[i].y, bodies[i].z} {
+			low[c] = math.Min(low[c], float64(x))
+			high[c] = math.Max(high[c], float64(x))
+		}
+	}
+	var middle [3]float64
+	var half float64
+	for c := range middle {
+		middle[c] = (low[c] + high[c]) / 2
+		half = math.Max(half, (high[c]-low[c])/2)
+	}
+	f.split(0, numBodies, middle, half, 0)
+	return nil
+}
+
+// add the cell of order[start:end] inside the cube around middle and
+// return its index, splitting it into octants if it has too many bodies
+func (f *FMM) split(start, end int, middle [3]float64, half float64, level int) int {
+	k := len(f.cells)
+	f.cells = append(f.cells, fmmCell{start: start, end: end})
+	for len(f.levels) <= level {
+		f.levels = append(f.levels, nil)
+	}
+	f.levels[level] = append(f.levels[level], k)
+
+	// COINCIDENT BODIES CANNOT BE SEPARATED, STOP AT A DEPTH OF 32
+	if end-start <= f.LeafSize || level >= 32 {
+		return k
+	}
+
+	octant := func(i int) int {
+		b := f.bodies[i]
+		o := 0
+		for c, x := range [3]float32{b.x, b.y, b.z} {
+			if float64(x) >= middle[c] {
+				o |= 1 << c
+			}
+		}
+		return o
+	}
+	var counts [9]int
+	for _, i := range f.order[start:end] {
+		counts[octant(i)+1]++
+	}
+	for o := 1; o < 9; o++ {
+		counts[o] += counts[o-1]
+	}
+	sorted := make([]int, end-start)
+	offsets := counts
+	for _, i := range f.order[start:end] {
+		o := octant(i)
+		sorted[offsets[o]] = i
+		offsets[o]++
+	}
+	copy(f.order[start:end], sorted)
+
+	var children []int
+	for o := 0; o < 8; o++ {
+		if counts[o] == counts[o+1] {
+			continue
+		}
+		var child [3]float64
+		for c := range child {
+			child[c] = middle[c] - half/2
+			if o&(1<<c) != 0 {
+				child[c] = middle[c] + half/2
+			}
+		}
+		children = append(children, f.split(start+counts[o], start+counts[o+1], child, half/2, level+1))
+	}
+	f.cells[k].children = children
+	return k
+}
+
+// Levels returns the number of levels of the tree
+func (f *FMM) Levels() int {
+	return len(f.levels)
+}
+
+// LevelCells returns the number of cells on a level of the tree
+func (f *FMM) LevelCells(level int) int {
+	return len(f.levels[level])
+}
+
+// Upward computes the multipole expansions of the cells [start, end) of a
+// level, the deeper levels must be done
+func (f *FMM) Upward(level, start, end int) {
+	for _, k := range f.levels[level][start:end] {
+		cell := &f.cells[k]
+		cell.multipole = make([]float64, len(f.terms))
+		cell.local = make([]float64, len(f.terms))
+		cell.center, cell.mass, cell.radius = [3]float64{}, 0, 0
+
+		for _, i := range f.order[cell.start:cell.end] {
+			b := f.bodies[i]
+			m := float64(b.mass)
+			cell.mass += m
+			cell.center[0] += m * float64(b.x)
+			cell.center[1] += m * float64(b.y)
+			cell.center[2] += m * float64(b.z)
+		}
+		if cell.mass > 0 {
+			for c := range cell.center {
+				cell.center[c] /= cell.mass
+			}
+		}
+		for _, i := range f.order[cell.start:cell.end] {
+			d := f.offset(i, cell.center)
+			cell.radius = math.Max(cell.radius, math.Sqrt(d[0]*d[0]+d[1]*d[1]+d[2]*d[2]))
+		}
+
+		if cell.children == nil {
+			// P2M: MOMENTS OF THE BODIES
+			for _, i := range f.order[cell.start:cell.end] {
+				p := f.powers(f.offset(i, cell.center))
+				m := float64(f.bodies[i].mass)
+				for n, t := range f.terms {
+					cell.multipole[n] += m * p[0][t[0]] * p[1][t[1]] * p[2][t[2]]
+				}
+			}
+			continue
+		}
+
+		// M2M: SHIFT THE MOMENTS OF THE CHILDREN TO THE CENTER
+		for _, child := range cell.children {
+			c := &f.cells[child]
+			p := f.powers([3]float64{c.center[0] - cell.center[0], c.center[1] - cell.center[1], c.center[2] - cell.center[2]})
+			for n, a := range f.terms {
+				for g, b := range f.terms[:n+1] {
+					if b[0] > a[0] || b[1] > a[1] || b[2] > a[2] {
+						continue
+					}
+					cell.multipole[n] += c.multipole[g] * p[0][a[0]-b[0]] * p[1][a[1]-b[1]] * p[2][a[2]-b[2]]
+				}
+			}
+		}
+	}
+}
+
+// offset of body i from a point
+func (f *FMM) offset(i int, from [3]float64) [3]float64 {
+	b := f.bodies[i]
+	return [3]float64{float64(b.x) - from[0], float64(b.y) - from[1], float64(b.z) - from[2]}
+}
+
+// derivatives ∂^(t,u,v) 1/r at r of every term, with the recurrence of
+// McMurchie and Davidson on F_n = (1/r d/dr)^n 1/r:
+// ∂x^(t+1) F_n = t ∂x^(t-1) F_(n+1) + x ∂x^t F_(n+1)
+func (f *FMM) derivatives(r [3]float64, scratch [][]float64) []float64 {
+	p := f.Order
+	r2 := r[0]*r[0] + r[1]*r[1] + r[2]*r[2]
+	inv := 1 / math.Sqrt(r2)
+	fn := inv
+	for n := 0; n <= p; n++ {
+		scratch[n][0] = fn
+		fn *= -float64(2*n+1) / r2
+	}
+
+	for s := 1; s <= p; s++ {
+		for n := 0; n <= p-s; n++ {
+			for k, t := range f.terms {
+				if t[0]+t[1]+t[2] != s {
+					continue
+				}
+				// LOWER THE FIRST NONZERO COMPONENT
+				c := 0
+				for t[c] == 0 {
+					c++
+				}
+				lower := t
+				lower[c]--
+				value := r[c] * scratch[n+1][f.term(lower[0], lower[1], lower[2])]
+				if lower[c] > 0 {
+					lower[c]--
+					value += float64(t[c]-1) * scratch[n+1][f.term(lower[0], lower[1], lower[2])]
+				}
+				scratch[n][k] = value
+			}
+		}
+	}
+	return scratch[0]
+}
+
+func (f *FMM) newScratch() [][]float64 {
+	scratch := make([][]float64, f.Order+1)
+	for n := range scratch {
+		scratch[n] = make([]float64, len(f.terms))
+	}
+	return scratch
+}
+
+// M2L: add the expansion of source b to the local expansion of target a
+func (f *FMM) multipoleToLocal(a, b *fmmCell, scratch [][]float64) {
+	r := [3]float64{a.center[0] - b.center[0], a.center[1] - b.center[1], a.center[2] - b.center[2]}
+	d := f.derivatives(r, scratch)
+	for n, beta := range f.terms {
+		order := beta[0] + beta[1] + beta[2]
+		var sum float64
+		for g, alpha := range f.terms {
+			s := alpha[0] + alpha[1] + alpha[2]
+			if s+order > f.Order {
+				break
+			}
+			term := b.multipole[g] * d[f.term(alpha[0]+beta[0], alpha[1]+beta[1], alpha[2]+beta[2])]
+			if s%2 == 1 {
+				term = -term
+			}
+			sum += term
+		}
+		a.local[n] -= sum
+	}
+}
+
+// L2L: shift the local expansion of a parent to a child
+func (f *FMM) localToLocal(parent, child *fmmCell) {
+	p := f.powers([3]float64{child.center[0] - parent.center[0], child.center[1] - parent.center[1], child.center[2] - parent.center[2]})
+	for n, beta := range f.terms {
+		for g := n; g < len(f.terms); g++ {
+			alpha := f.terms[g]
+			if alpha[0] < beta[0] || alpha[1] < beta[1] || alpha[2] < beta[2] {
+				continue
+			}
+			child.local[n] += parent.local[g] * p[0][alpha[0]-beta[0]] * p[1][alpha[1]-beta[1]] * p[2][alpha[2]-beta[2]]
+		}
+	}
+}
+
+// visit the sources of a branch: well separated cells are added to its
+// local expansion and the rest is returned for its children, or for a
+// leaf, as the leaves to interact with directly
+func (f *FMM) visit(branch fmmBranch, scratch [][]float64) []int {
+	a := &f.cells[branch.cell]
+	var remaining []int
+	queue := append([]int(nil), branch.sources...)
+	for len(queue) > 0 {
+		k := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		b := &f.cells[k]
+
+		dx, dy, dz := a.center[0]-b.center[0], a.center[1]-b.center[1], a.center[2]-b.center[2]
+		distance := math.Sqrt(dx*dx + dy*dy + dz*dz)
+		if a.radius+b.radius < f.Theta*distance && distance-a.radius-b.radius > f.near {
+			f.multipoleToLocal(a, b, scratch)
+		} else if b.children != nil && (a.children == nil || b.radius > a.radius) {
+			queue = append(queue, b.children...) // OPEN THE LARGER CELL
+		} else {
+			remaining = append(remaining, k)
+		}
+	}
+	return remaining
+}
+
+// L2P and P2P: accelerations of the bodies of a leaf from its local
+// expansion and the direct force of the bodies of the given leaves
+func (f *FMM) evaluate(a *fmmCell, leaves []int) {
+	for _, i := range f.order[a.start:a.end] {
+		p := f.powers(f.offset(i, a.center))
+		var acc [3]float64
+		for _, beta := range f.terms {
+			if beta[0]+beta[1]+beta[2] == f.Order {
+				break
+			}
+			weight := p[0][beta[0]] * p[1][beta[1]] * p[2][beta[2]]
+			acc[0] -= a.local[f.term(beta[0]+1, beta[1], beta[2])] * weight
+			acc[1] -= a.local[f.term(beta[0], beta[1]+1, beta[2])] * weight
+			acc[2] -= a.local[f.term(beta[0], beta[1], beta[2]+1)] * weight
+		}
+
+		body := f.bodies[i]
+		var Fx, Fy, Fz float32
+		for _, k := range leaves {
+			b := &f.cells[k]
+			for _, j := range f.order[b.start:b.end] {
+				dx, dy, dz := f.physics.separation(body, f.bodies[j])
+				factor := f.physics.Kernel.ForceFactor(dx*dx+dy*dy+dz*dz) * f.bodies[j].mass
+				Fx += dx * factor
+				Fy += dy * factor
+				Fz += dz * factor
+			}
+		}
+		body.ax = float32(acc[0]) + Fx
+		body.ay = float32(acc[1]) + Fy
+		body.az = float32(acc[2]) + Fz
+		if f.physics.External != nil {
+			AddExternalAcceleration(i, f.bodies, f.physics.External)
+		}
+	}
+}
+
+// run the downward pass on the subtree of a branch
+func (f *FMM) descend(branch fmmBranch, scratch [][]float64) {
+	remaining := f.visit(branch, scratch)
+	a := &f.cells[branch.cell]
+	if a.children == nil {
+		f.evaluate(a, remaining)
+		return
+	}
+	for _, child := range a.children {
+		f.localToLocal(a, &f.cells[child])
+		f.descend(fmmBranch{child, remaining}, scratch)
+	}
+}
+
+// Branches starts the downward pass from the root and returns the number
+// of independent branches left, at least minimum unless the tree has fewer
+// cells to split. The multipole expansions must be done.
+func (f *FMM) Branches(minimum int) int {
+	f.branches = f.branches[:0]
+	if len(f.cells) == 0 {
+		return 0
+	}
+	f.branches = append(f.branches, fmmBranch{0, []int{0}})
+
+	// SPLIT BREADTH FIRST SO THE BRANCHES HAVE SIMILAR SIZES
+	scratch := f.newScratch()
+	for split := 0; len(f.branches) < minimum && split < len(f.branches); {
+		branch := f.branches[split]
+		a := &f.cells[branch.cell]
+		if a.children == nil {
+			split++
+			continue
+		}
+		remaining := f.visit(branch, scratch)
+		f.branches = append(f.branches[:split], f.branches[split+1:]...)
+		for _, child := range a.children {
+			f.localToLocal(a, &f.cells[child])
+			f.branches = append(f.branches, fmmBranch{child, remaining})
+		}
+	}
+	return len(f.branches)
+}
+
+// Descend finishes the downward pass of a branch and sets the
+// accelerations of its bodies
+func (f *FMM) Descend(branch int) {
+	f.descend(f.branches[branch], f.newScratch())
+}
+
+// Solve computes the accelerations of all bodies
+func (f *FMM) Solve(bodies []*Body, numBodies int, physics *Physics) error {
+	if err := f.Build(bodies, numBodies, physics); err != nil {
+		return err
+	}
+	for level := f.Levels() - 1; level >= 0; level-- {
+		f.Upward(level, 0, f.LevelCells(level))
+	}
+	branches := f.Branches(1)
+	for branch := 0; branch < branches; branch++ {
+		f.Descend(branch)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package nbody
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ForceLaw is a pairwise interaction that replaces Newtonian gravity
+type ForceLaw interface {
+	Name() string
+	// Acceleration of body a due to body b, (dx, dy, dz) is the separation
+	// from a to b
+	Acceleration(a, b *Body, dx, dy, dz float32) (ax, ay, az float32)
+	// Potential energy of the pair at squared distance r2
+	PairPotential(a, b *Body, r2 float64) float64
+}
+
+// BodyInitializer is implemented by force laws that need per body state,
+// e.g. charges, it is called once for every body after initialization
+type BodyInitializer interface {
+	InitBody(id int, bodies []*Body)
+}
+
+// ForceLawFactory returns a force law from its parameters, kernel is the
+// softening of the configuration
+type ForceLawFactory func(params map[string]float64, kernel SofteningKernel) (ForceLaw, error)
+
+var forceLaws = map[string]ForceLawFactory{}
+
+// RegisterForceLaw makes a force law selectable by name, it panics if the
+// name is already taken
+func RegisterForceLaw(name string, factory ForceLawFactory) {
+	if _, ok := forceLaws[name]; ok || name == "gravity" {
+		panic("nbody: force law " + name + " registered twice")
+	}
+	forceLaws[name] = factory
+}
+
+// ForceLaws returns the names of the registered force laws
+func ForceLaws() []string {
+	names := []string{"gravity"}
+	for name := range forceLaws {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// ParseForceLaw returns the force law of a spec "<name>[:<key>=<value>,...]",
+// e.g. "yukawa:lambda=50". Newtonian gravity ("gravity" or "") returns nil,
+// it is computed with the softening kernel.
+func ParseForceLaw(spec string, kernel SofteningKernel) (ForceLaw, error) {
+	name, params := spec, ""
+	if colon := strings.IndexByte(spec, ':'); colon >= 0 {
+		name, params = spec[:colon], spec[colon+1:]
+	}
+	name = strings.TrimSpace(name)
+	values, err := parseParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || name == "gravity" {
+		if len(values) > 0 {
+			return nil, fmt.Errorf("gravity takes no parameters, use the softening options")
+		}
+		return nil, nil
+	}
+
+	factory, ok := forceLaws[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown force law %q, known laws: %s", name, strings.Join(ForceLaws(), ", "))
+	}
+	return factory(values, kernel)
+}
+
+// parse "<key>=<value>,..." into a map
+func parseParams(params string) (map[string]float64, error) {
+	values := make(map[string]float64)
+	for _, param := range strings.Split(params, ",") {
+		if strings.TrimSpace(param) == "" {
+			continue
+		}
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid parameter %q", param)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[strings.TrimSpace(kv[0])] = value
+	}
+	return values, nil
+}
+
+// return params[key] or def if it is not set
+func param(params map[string]float64, key string, def float64) float64 {
+	if value, ok := params[key]; ok {
+		return value
+	}
+	return def
+}
+
+// return an error for parameters of a law that are not in allowed
+func checkParams(law string, params map[string]float64, allowed ...string) error {
+	for key := range params {
+		found := false
+		for _, a := range allowed {
+			found = found || key == a
+		}
+		if !found {
+			return fmt.Errorf("unknown parameter %q of force law %s", key, law)
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterForceLaw("yukawa", func(params map[string]float64, kernel SofteningKernel) (ForceLaw, error) {
+		law := Yukawa{Lambda: param(params, "lambda", 100), Eps: float64(kernel.Length())}
+		if law.Lambda <= 0 {
+			return nil, fmt.Errorf("yukawa screening length must be positive")
+		}
+		return law, checkParams("yukawa", params, "lambda")
+	})
+	RegisterForceLaw("coulomb", func(params map[string]float64, kernel SofteningKernel) (ForceLaw, error) {
+		law := Coulomb{K: param(params, "k", 1), Charge: float32(param(params, "q", 1)), Eps: float64(kernel.Length())}
+		return law, checkParams("coulomb", params, "k", "q")
+	})
+	RegisterForceLaw("lennard-jones", func(params map[string]float64, kernel SofteningKernel) (ForceLaw, error) {
+		law := LennardJones{Epsilon: param(params, "epsilon", 1), Sigma: param(params, "sigma", 1)}
+		law.Cutoff = param(params, "cutoff", 2.5*law.Sigma)
+		if law.Sigma <= 0 {
+			return nil, fmt.Errorf("lennard-jones sigma must be positive")
+		}
+		return law, checkParams("lennard-jones", params, "epsilon", "sigma", "cutoff")
+	})
+	RegisterForceLaw("1pn", func(params map[string]float64, kernel SofteningKernel) (ForceLaw, error) {
+		law := PostNewtonian{C: param(params, "c", 1e4), Eps: float64(kernel.Length())}
+		if law.C <= 0 {
+			return nil, fmt.Errorf("1pn speed of light must be positive")
+		}
+		return law, checkParams("1pn", params, "c")
+	})
+}
+
+// Yukawa is screened gravity, the potential of a mass m is
+// -m exp(-r/Lambda)/r with r softened like Plummer
+type Yukawa struct {
+	Lambda float64
+	Eps    float64
+}
+
+func (Yukawa) Name() string { return "yukawa" }
+
+func (y Yukawa) Acceleration(a, b *Body, dx, dy, dz float32) (ax, ay, az float32) {
+	r := math.Sqrt(float64(dx*dx+dy*dy+dz*dz) + y.Eps*y.Eps)
+	if r == 0 {
+		return 0, 0, 0
+	}
+	factor := float32(float64(b.mass) * (1 + r/y.Lambda) * math.Exp(-r/y.Lambda) / (r * r * r))
+	return dx * factor, dy * factor, dz * factor
+}
+
+func (y Yukawa) PairPotential(a, b *Body, r2 float64) float64 {
+	r := math.Sqrt(r2 + y.Eps*y.Eps)
+	if r == 0 {
+		return 0
+	}
+	return -float64(a.mass) * float64(b.mass) * math.Exp(-r/y.Lambda) / r
+}
+
+// Coulomb is the electrostatic force K qa qb / r², like charges repel. Its
+// bodies get charges of alternating sign +Charge, -Charge so the system is
+// neutral.
+type Coulomb struct {
+	K      float64
+	Charge float32
+	Eps    float64
+}
+
+func (Coulomb) Name() string { return "coulomb" }
+
+func (c Coulomb) InitBody(id int, bodies []*Body) {
+	bodies[id].charge = c.Charge
+	if id%2 == 1 {
+		bodies[id].charge = -c.Charge
+	}
+}
+
+func (c Coulomb) Acceleration(a, b *Body, dx, dy, dz float32) (ax, ay, az float32) {
+	r2 := float64(dx*dx+dy*dy+dz*dz) + c.Eps*c.Eps
+	if r2 == 0 || a.mass == 0 {
+		return 0, 0, 0
+	}
+	factor := float32(-c.K * float64(a.charge) * float64(b.charge) / (float64(a.mass) * r2 * math.Sqrt(r2)))
+	return dx * factor, dy * factor, dz * factor
+}
+
+func (c Coulomb) PairPotential(a, b *Body, r2 float64) float64 {
+	r2 += c.Eps * c.Eps
+	if r2 == 0 {
+		return 0
+	}
+	return c.K * float64(a.charge) * float64(b.charge) / math.Sqrt(r2)
+}
+
+// LennardJones is the 12-6 potential 4 Epsilon ((Sigma/r)^12 - (Sigma/r)^6)
+// cut off (and shifted to zero) at Cutoff
+type LennardJones struct {
+	Epsilon, Sigma, Cutoff float64
+}
+
+func (LennardJones) Name() string { return "lennard-jones" }
+
+func (lj LennardJones) Acceleration(a, b *Body, dx, dy, dz float32) (ax, ay, az float32) {
+	r2 := float64(dx*dx + dy*dy + dz*dz)
+	if r2 == 0 || r2 >= lj.Cutoff*lj.Cutoff || a.mass == 0 {
+		return 0, 0, 0
+	}
+	s6 := math.Pow(lj.Sigma*lj.Sigma/r2, 3)
+	// dV/dr / r, THE FORCE ON a IS dV/dr ALONG THE SEPARATION TO b
+	factor := float32(24 * lj.Epsilon * (s6 - 2*s6*s6) / (r2 * float64(a.mass)))
+	return dx * factor, dy * factor, dz * factor
+}
+
+func (lj LennardJones) PairPotential(a, b *Body, r2 float64) float64 {
+	if r2 == 0 || r2 >= lj.Cutoff*lj.Cutoff {
+		return 0
+	}
+	s6 := math.Pow(lj.Sigma*lj.Sigma/r2, 3)
+	c6 := math.Pow(lj.Sigma/lj.Cutoff, 6)
+	return 4 * lj.Epsilon * ((s6*s6 - s6) - (c6*c6 - c6))
+}
+
+// PostNewtonian is gravity with the first post-Newtonian corrections of the
+// Einstein-Infeld-Hoffmann equations for a speed of light C. Every pair is
+// treated as an isolated binary (the potentials and accelerations in the
+// corrections only include the two bodies), which is exact for two bodies
+// and a good approximation for compact binaries in a wider system. The
+// potential energy is the Newtonian one.
+type PostNewtonian struct {
+	C   float64
+	Eps float64
+}
+
+func (PostNewtonian) Name() string { return "1pn" }
+
+func (pn PostNewtonian) Acceleration(a, b *Body, dx, dy, dz float32) (ax, ay, az float32) {
+	r2 := float64(dx*dx+dy*dy+dz*dz) + pn.Eps*pn.Eps
+	if r2 == 0 {
+		return 0, 0, 0
+	}
+	r := math.Sqrt(r2)
+	c2 := pn.C * pn.C
+	ma, mb := float64(a.mass), float64(b.mass)
+	n := [3]float64{-float64(dx) / r, -float64(dy) / r, -float64(dz) / r} // FROM b TO a
+	va := [3]float64{float64(a.vx), float64(a.vy), float64(a.vz)}
+	vb := [3]float64{float64(b.vx), float64(b.vy), float64(b.vz)}
+	dot := func(u, v [3]float64) float64 { return u[0]*v[0] + u[1]*v[1] + u[2]*v[2] }
+
+	// b IS ACCELERATED TOWARDS a BY a ALONE, SO (xb - xa).ab = -ma/r
+	vbn := dot(vb, n)
+	radial := 1 + (-4*mb/r-ma/r+dot(va, va)+2*dot(vb, vb)-4*dot(va, vb)-1.5*vbn*vbn-0.5*ma/r)/c2
+	var w [3]float64
+	for k := range w {
+		w[k] = 4*va[k] - 3*vb[k]
+	}
+	velocity := dot(n, w) / c2
+
+	var acc [3]float64
+	for k := range acc {
+		acc[k] = mb / r2 * (-n[k]*radial + velocity*(va[k]-vb[k]))
+		acc[k] += 3.5 / c2 * mb / r * ma * n[k] / r2 // 7/2 mb ab / r
+	}
+	return float32(acc[0]), float32(acc[1]), float32(acc[2])
+}
+
+func (pn PostNewtonian) PairPotential(a, b *Body, r2 float64) float64 {
+	r2 += pn.Eps * pn.Eps
+	if r2 == 0 {
+		return 0
+	}
+	return -float64(a.mass) * float64(b.mass) / math.Sqrt(r2)
+}
+
+// Charge returns the charge of a body
+func (b *Body) Charge() float32 {
+	return b.charge
+}
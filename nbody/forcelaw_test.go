@@ -0,0 +1,82 @@
+package nbody
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseForceLaw(t *testing.T) {
+	kernel := Plummer{Eps: 0.1}
+	tests := []struct {
+		spec string
+		want ForceLaw
+	}{
+		{"", nil},
+		{"gravity", nil},
+		{"yukawa", Yukawa{Lambda: 100, Eps: float64(float32(0.1))}},
+		{"yukawa:lambda=50", Yukawa{Lambda: 50, Eps: float64(float32(0.1))}},
+		{"coulomb: k=2, q=0.5", Coulomb{K: 2, Charge: 0.5, Eps: float64(float32(0.1))}},
+		{"lennard-jones", LennardJones{Epsilon: 1, Sigma: 1, Cutoff: 2.5}},
+		{"lennard-jones:sigma=2", LennardJones{Epsilon: 1, Sigma: 2, Cutoff: 5}},
+		{"lennard-jones:sigma=2,cutoff=3", LennardJones{Epsilon: 1, Sigma: 2, Cutoff: 3}},
+		{"1pn", PostNewtonian{C: 1e4, Eps: float64(float32(0.1))}},
+	}
+	for _, test := range tests {
+		law, err := ParseForceLaw(test.spec, kernel)
+		if err != nil || law != test.want {
+			t.Errorf("ParseForceLaw(%q) = %+v, %v, want %+v", test.spec, law, err, test.want)
+		}
+	}
+
+	errors := []struct {
+		spec, message string
+	}{
+		{"magnetism", "unknown force law"},
+		{"yukawa:range=5", "unknown parameter"},
+		{"yukawa:lambda", "invalid parameter"},
+		{"yukawa:lambda=x", "invalid syntax"},
+		{"yukawa:lambda=-1", "must be positive"},
+		{"gravity:g=2", "no parameters"},
+		{"lennard-jones:sigma=0", "must be positive"},
+	}
+	for _, test := range errors {
+		if _, err := ParseForceLaw(test.spec, kernel); err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("ParseForceLaw(%q) returned %v, want an error about %q", test.spec, err, test.message)
+		}
+	}
+}
+
+// the acceleration of every law is the force of its pair potential,
+// ma a = V'(r) d / r for the separation d from a to b
+func TestForceLawPotential(t *testing.T) {
+	laws := []ForceLaw{
+		Yukawa{Lambda: 2, Eps: 0.1},
+		Coulomb{K: 2, Charge: 0.5, Eps: 0.1},
+		LennardJones{Epsilon: 1, Sigma: 1, Cutoff: 2.5},
+		PostNewtonian{C: 1e4, Eps: 0.1},
+	}
+	for _, law := range laws {
+		bodies := []*Body{
+			NewBodyFromState(BodyState{Mass: 2}),
+			NewBodyFromState(BodyState{Mass: 3}),
+		}
+		if initializer, ok := law.(BodyInitializer); ok {
+			initializer.InitBody(0, bodies)
+			initializer.InitBody(1, bodies)
+		}
+		a, b := bodies[0], bodies[1]
+		for _, d := range [][3]float32{{0.9, 0.5, -0.3}, {1.2, 0, 0}, {0, -0.7, 1.5}} {
+			ax, ay, az := law.Acceleration(a, b, d[0], d[1], d[2])
+			r := math.Sqrt(float64(d[0]*d[0] + d[1]*d[1] + d[2]*d[2]))
+			const h = 1e-6
+			dV := (law.PairPotential(a, b, (r+h)*(r+h)) - law.PairPotential(a, b, (r-h)*(r-h))) / (2 * h)
+			for c, acc := range [3]float32{ax, ay, az} {
+				want := dV * float64(d[c]) / r / float64(a.mass)
+				if math.Abs(float64(acc)-want) > 1e-3*math.Max(1e-3, math.Abs(want)) {
+					t.Errorf("%s at %v: acceleration %g along %d, the potential gives %g", law.Name(), d, acc, c, want)
+				}
+			}
+		}
+	}
+}
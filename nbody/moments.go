@@ -0,0 +1,45 @@
+package nbody
+
+// Moments are the mass, the mass weighted position and the momentum of a
+// set of bodies, they add up over disjoint sets
+type Moments struct {
+	Mass         float64
+	MassPosition [3]float64
+	Momentum     [3]float64
+}
+
+// return the moments of the bodies [start, end)
+func BodyMoments(bodies []*Body, start, end int) Moments {
+	var m Moments
+	for i := start; i < end; i++ {
+		b := bodies[i]
+		mass := float64(b.mass)
+		m.Mass += mass
+		m.MassPosition[0] += mass * float64(b.x)
+		m.MassPosition[1] += mass * float64(b.y)
+		m.MassPosition[2] += mass * float64(b.z)
+		m.Momentum[0] += mass * float64(b.vx)
+		m.Momentum[1] += mass * float64(b.vy)
+		m.Momentum[2] += mass * float64(b.vz)
+	}
+	return m
+}
+
+// Add returns the moments of the bodies of both sets
+func (m Moments) Add(other Moments) Moments {
+	m.Mass += other.Mass
+	for c := range m.MassPosition {
+		m.MassPosition[c] += other.MassPosition[c]
+		m.Momentum[c] += other.Momentum[c]
+	}
+	return m
+}
+
+// CenterOfMass returns the center of mass, the origin if there is no mass.
+// In a periodic box it is the center of mass of the wrapped positions.
+func (m Moments) CenterOfMass() [3]float64 {
+	if m.Mass == 0 {
+		return [3]float64{}
+	}
+	return [3]float64{m.MassPosition[0] / m.Mass, m.MassPosition[1] / m.Mass, m.MassPosition[2] / m.Mass}
+}
@@ -1,15 +1,19 @@
 package nbody
 
 import (
-	"fmt"
 	"math"
 	"math/rand"
-	"os"
 )
 
 type Body struct {
+	id         int     // STABLE BODY ID
 	x, y, z    float32 // POSITIONS
 	vx, vy, vz float32 // VELOCITIES
+	ax, ay, az float32 // ACCELERATIONS FROM THE LAST FORCE COMPUTATION
+	mass       float32 // MASS
+	level      int     // BLOCK TIMESTEP LEVEL, THE BODY STEPS dtMax / 2^level
+	radius     float32 // COLLISION RADIUS
+	charge     float32 // CHARGE, ONLY USED BY THE COULOMB FORCE LAW
 }
 
 // return a new body
@@ -17,20 +21,6 @@ func NewBody() *Body {
 	return &Body{}
 }
 
-// write to csv
-func ParticlePositionsToCSV(file *os.File, iteration int,
-	bodies []*Body, numBodies int) {
-	for i := 0; i < numBodies; i++ {
-		_, err := fmt.Fprintf(file, "%d, %e, %e, %e\n",
-			iteration, bodies[i].x, bodies[i].y, bodies[i].z)
-
-		if err != nil {
-			fmt.Println("ERROR WHEN WRITING TO FILE \"nbody.csv\"")
-			panic(err)
-		}
-	}
-}
-
 // initialize n bodies with random positions and velocities
 func InitPositionsAndVelocities(id int, bodies []*Body, numBodies int) {
 	random := func(a, b float32) float32 {
@@ -38,6 +28,8 @@ func InitPositionsAndVelocities(id int, bodies []*Body, numBodies int) {
 	}
 
 	bodies[id] = NewBody()
+	bodies[id].id = id
+	bodies[id].mass = 1.0
 
 	if id%3 == 0 {
 		bodies[id].x = -1000.0 + random(-2.2, 3.3)
@@ -60,31 +52,86 @@ func InitPositionsAndVelocities(id int, bodies []*Body, numBodies int) {
 
 // compute interbody forces
 func ComputeBodyForce(id int, bodies []*Body, dt float32,
-	numBodies int, softeningFactor float32) {
+	numBodies int, physics *Physics) {
+	ComputeBodyAcceleration(id, bodies, numBodies, physics)
+	KickBody(id, bodies, dt)
+}
+
+// compute the acceleration of a body from every other body without
+// updating its velocity
+func ComputeBodyAcceleration(id int, bodies []*Body, numBodies int, physics *Physics) {
 	var Fx, Fy, Fz float32
-	for j := 0; j < numBodies; j++ {
-		dx := bodies[j].x - bodies[id].x
-		dy := bodies[j].y - bodies[id].y
-		dz := bodies[j].z - bodies[id].z
-
-		distSqr := dx*dx + dy*dy + dz*dz + softeningFactor
-		invrDist := float32(1.0 / math.Pow(float64(distSqr), 0.5))
-		invrDist3 := invrDist * invrDist * invrDist
-
-		Fx += dx * invrDist3
-		Fy += dy * invrDist3
-		Fz += dz * invrDist3
+	if plummer, ok := physics.Kernel.(Plummer); ok && !physics.Box.Periodic() && physics.Law == nil {
+		// PLUMMER SOFTENING INLINED, IT IS THE DEFAULT AND THE HOTTEST LOOP
+		softeningFactor := plummer.Eps * plummer.Eps
+		for j := 0; j < numBodies; j++ {
+			dx := bodies[j].x - bodies[id].x
+			dy := bodies[j].y - bodies[id].y
+			dz := bodies[j].z - bodies[id].z
+
+			distSqr := dx*dx + dy*dy + dz*dz + softeningFactor
+			invrDist := float32(1.0 / math.Pow(float64(distSqr), 0.5))
+			invrDist3 := invrDist * invrDist * invrDist
+
+			Fx += dx * invrDist3 * bodies[j].mass
+			Fy += dy * invrDist3 * bodies[j].mass
+			Fz += dz * invrDist3 * bodies[j].mass
+		}
+	} else if physics.Law != nil {
+		for j := 0; j < numBodies; j++ {
+			if j == id {
+				continue
+			}
+			dx, dy, dz := physics.separation(bodies[id], bodies[j])
+			ax, ay, az := physics.Law.Acceleration(bodies[id], bodies[j], dx, dy, dz)
+			Fx += ax
+			Fy += ay
+			Fz += az
+		}
+	} else {
+		for j := 0; j < numBodies; j++ {
+			dx, dy, dz := physics.separation(bodies[id], bodies[j])
+
+			factor := physics.Kernel.ForceFactor(dx*dx+dy*dy+dz*dz) * bodies[j].mass
+			Fx += dx * factor
+			Fy += dy * factor
+			Fz += dz * factor
+
+			if physics.Ewald != nil {
+				// FORCE FROM THE OTHER PERIODIC IMAGES
+				ex, ey, ez, _ := physics.Ewald.Correction(dx, dy, dz)
+				Fx += ex * bodies[j].mass
+				Fy += ey * bodies[j].mass
+				Fz += ez * bodies[j].mass
+			}
+		}
 	}
 
-	bodies[id].vx += dt * Fx
-	bodies[id].vy += dt * Fy
-	bodies[id].vz += dt * Fz
+	bodies[id].ax = Fx
+	bodies[id].ay = Fy
+	bodies[id].az = Fz
 
+	if physics.External != nil {
+		AddExternalAcceleration(id, bodies, physics.External)
+	}
+}
+
+// update the velocity of a body with its last computed acceleration
+func KickBody(id int, bodies []*Body, dt float32) {
+	bodies[id].vx += dt * bodies[id].ax
+	bodies[id].vy += dt * bodies[id].ay
+	bodies[id].vz += dt * bodies[id].az
 }
 
-// integrate postions
-func IntegratePositions(id int, bodies []*Body, numBodies int, dt float32) {
+// integrate postions, wrapping them into the box if it is periodic
+func IntegratePositions(id int, bodies []*Body, numBodies int, dt float32, box Box) {
 	bodies[id].x += bodies[id].vx * dt
 	bodies[id].y += bodies[id].vy * dt
 	bodies[id].z += bodies[id].vz * dt
+
+	if box.Periodic() {
+		bodies[id].x = box.Wrap(bodies[id].x)
+		bodies[id].y = box.Wrap(bodies[id].y)
+		bodies[id].z = box.Wrap(bodies[id].z)
+	}
 }
@@ -0,0 +1,191 @@
+package nbody
+
+import "math"
+
+// Box is a periodic cube [-Size/2, Size/2)³, a zero Size is open space
+type Box struct {
+	Size float32
+}
+
+// Periodic returns whether the box has periodic boundaries
+func (box Box) Periodic() bool {
+	return box.Size > 0
+}
+
+// Wrap maps a coordinate into the box
+func (box Box) Wrap(x float32) float32 {
+	if !box.Periodic() {
+		return x
+	}
+	half := box.Size / 2
+	x = float32(math.Mod(float64(x+half), float64(box.Size)))
+	if x < 0 {
+		x += box.Size
+	}
+	return x - half
+}
+
+// MinimumImage maps a separation to the nearest periodic image
+func (box Box) MinimumImage(d float32) float32 {
+	if !box.Periodic() {
+		return d
+	}
+	return d - box.Size*float32(math.Round(float64(d/box.Size)))
+}
+
+// EwaldTable holds the difference between the Ewald sum (a unit mass, all
+// its periodic images and a neutralizing background) and the minimum image
+// inverse-square force and potential, tabulated on one octant of the box
+// and interpolated trilinearly
+type EwaldTable struct {
+	size  float32
+	cells int          // Grid cells per half box
+	force [][3]float32 // (cells+1)³ force corrections
+	pot   []float64    // (cells+1)³ potential corrections
+}
+
+// return the Ewald corrections for a box of the given size, cells is the
+// resolution of the table per half box
+func NewEwaldTable(size float32, cells int) *EwaldTable {
+	t := &EwaldTable{
+		size:  size,
+		cells: cells,
+		force: make([][3]float32, (cells+1)*(cells+1)*(cells+1)),
+		pot:   make([]float64, (cells+1)*(cells+1)*(cells+1)),
+	}
+
+	l := float64(size)
+	for i := 0; i <= cells; i++ {
+		for j := 0; j <= cells; j++ {
+			for k := 0; k <= cells; k++ {
+				d := [3]float64{
+					float64(i) / float64(cells) * l / 2,
+					float64(j) / float64(cells) * l / 2,
+					float64(k) / float64(cells) * l / 2,
+				}
+				force, pot := ewaldCorrection(d, l)
+				n := t.index(i, j, k)
+				t.force[n] = [3]float32{float32(force[0]), float32(force[1]), float32(force[2])}
+				t.pot[n] = pot
+			}
+		}
+	}
+	return t
+}
+
+func (t *EwaldTable) index(i, j, k int) int {
+	return (i*(t.cells+1)+j)*(t.cells+1) + k
+}
+
+// ewald sum minus the minimum image term for a unit mass at separation d in
+// a box of side l (Hernquist, Bouchet & Suto 1991), the force is the
+// acceleration towards the mass and the potential is per unit mass
+func ewaldCorrection(d [3]float64, l float64) ([3]float64, float64) {
+	alpha := 2 / l
+	volume := l * l * l
+	r := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+
+	var force [3]float64
+	pot := math.Pi / (alpha * alpha * volume)
+
+	// REAL SPACE SUM OVER IMAGES
+	for nx := -4; nx <= 4; nx++ {
+		for ny := -4; ny <= 4; ny++ {
+			for nz := -4; nz <= 4; nz++ {
+				dn := [3]float64{d[0] + float64(nx)*l, d[1] + float64(ny)*l, d[2] + float64(nz)*l}
+				rn := math.Sqrt(dn[0]*dn[0] + dn[1]*dn[1] + dn[2]*dn[2])
+				if rn == 0 {
+					// SELF IMAGE: lim erf(alpha r) / r
+					pot += 2 * alpha / math.Sqrt(math.Pi)
+					continue
+				}
+				if rn > 4.5*l {
+					continue
+				}
+				if nx == 0 && ny == 0 && nz == 0 {
+					// THE MINIMUM IMAGE TERM IS COMPUTED DIRECTLY, KEEP ONLY ITS ERF PART
+					pot += math.Erf(alpha*rn) / rn
+					g := (-math.Erf(alpha*rn) + 2*alpha*rn/math.Sqrt(math.Pi)*math.Exp(-alpha*alpha*rn*rn)) / (rn * rn * rn)
+					for c := range force {
+						force[c] += dn[c] * g
+					}
+					continue
+				}
+				pot -= math.Erfc(alpha*rn) / rn
+				g := (math.Erfc(alpha*rn) + 2*alpha*rn/math.Sqrt(math.Pi)*math.Exp(-alpha*alpha*rn*rn)) / (rn * rn * rn)
+				for c := range force {
+					force[c] += dn[c] * g
+				}
+			}
+		}
+	}
+
+	// FOURIER SPACE SUM
+	for hx := -4; hx <= 4; hx++ {
+		for hy := -4; hy <= 4; hy++ {
+			for hz := -4; hz <= 4; hz++ {
+				h2 := hx*hx + hy*hy + hz*hz
+				if h2 == 0 || h2 > 16 {
+					continue
+				}
+				k := [3]float64{2 * math.Pi * float64(hx) / l, 2 * math.Pi * float64(hy) / l, 2 * math.Pi * float64(hz) / l}
+				k2 := k[0]*k[0] + k[1]*k[1] + k[2]*k[2]
+				kd := k[0]*d[0] + k[1]*d[1] + k[2]*d[2]
+				w := 4 * math.Pi / (volume * k2) * math.Exp(-k2/(4*alpha*alpha))
+				pot -= w * math.Cos(kd)
+				for c := range force {
+					force[c] += w * math.Sin(kd) * k[c]
+				}
+			}
+		}
+	}
+
+	if r == 0 {
+		force = [3]float64{}
+	}
+	return force, pot
+}
+
+// Correction returns the Ewald force and potential corrections for a
+// minimum image separation (dx, dy, dz) pointing from a body to a unit mass
+func (t *EwaldTable) Correction(dx, dy, dz float32) (fx, fy, fz float32, pot float64) {
+	// THE FORCE CORRECTION IS ODD AND THE POTENTIAL EVEN IN EVERY COORDINATE
+	d := [3]float32{dx, dy, dz}
+	var sign [3]float32
+	var cell [3]int
+	var frac [3]float32
+	scale := float32(t.cells) / (t.size / 2)
+	for c := range d {
+		sign[c] = 1
+		if d[c] < 0 {
+			sign[c], d[c] = -1, -d[c]
+		}
+		u := d[c] * scale
+		if u >= float32(t.cells) {
+			u = float32(t.cells) - 1e-3
+		}
+		cell[c] = int(u)
+		frac[c] = u - float32(cell[c])
+	}
+
+	var f [3]float32
+	for corner := 0; corner < 8; corner++ {
+		w := float32(1)
+		var idx [3]int
+		for c := 0; c < 3; c++ {
+			if corner&(1<<c) != 0 {
+				w *= frac[c]
+				idx[c] = cell[c] + 1
+			} else {
+				w *= 1 - frac[c]
+				idx[c] = cell[c]
+			}
+		}
+		n := t.index(idx[0], idx[1], idx[2])
+		for c := range f {
+			f[c] += w * t.force[n][c]
+		}
+		pot += float64(w) * t.pot[n]
+	}
+	return sign[0] * f[0], sign[1] * f[1], sign[2] * f[2], pot
+}
@@ -0,0 +1,95 @@
+package nbody
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBoxWrap(t *testing.T) {
+	box := Box{Size: 10}
+	for _, x := range []float32{0, 4.9, 5, -5, -5.1, 12, -17, 31.5, 1000.25} {
+		w := box.Wrap(x)
+		if w < -5 || w >= 5 {
+			t.Errorf("Wrap(%g) = %g is outside [-5, 5)", x, w)
+		}
+		// THE WRAPPED COORDINATE IS AN IMAGE OF x
+		if shift := float64(x-w) / 10; math.Abs(shift-math.Round(shift)) > 1e-4 {
+			t.Errorf("Wrap(%g) = %g is not an image", x, w)
+		}
+	}
+	if w := (Box{}).Wrap(123); w != 123 {
+		t.Errorf("open space wrapped 123 to %g", w)
+	}
+}
+
+func TestBoxMinimumImage(t *testing.T) {
+	box := Box{Size: 10}
+	tests := []struct {
+		d, want float32
+	}{
+		{0, 0},
+		{4.9, 4.9},
+		{6, -4},
+		{-6, 4},
+		{16, -4},
+		{-23, -3},
+	}
+	for _, test := range tests {
+		if d := box.MinimumImage(test.d); math.Abs(float64(d-test.want)) > 1e-5 {
+			t.Errorf("MinimumImage(%g) = %g, want %g", test.d, d, test.want)
+		}
+	}
+	if d := (Box{}).MinimumImage(16); d != 16 {
+		t.Errorf("open space mapped 16 to %g", d)
+	}
+}
+
+// on the corners of a cube of half the box every body is pulled equally
+// by all the images of the others, so the Ewald forces cancel although the
+// minimum image forces alone do not
+func TestEwaldSymmetricLattice(t *testing.T) {
+	const size = 4
+	box := Box{Size: size}
+	table := NewEwaldTable(size, 16)
+	var lattice [][3]float32
+	for _, x := range []float32{-1, 1} {
+		for _, y := range []float32{-1, 1} {
+			for _, z := range []float32{-1, 1} {
+				lattice = append(lattice, [3]float32{x, y, z})
+			}
+		}
+	}
+
+	for i, a := range lattice {
+		var newton, ewald [3]float64
+		for j, b := range lattice {
+			if i == j {
+				continue
+			}
+			d := [3]float32{box.MinimumImage(b[0] - a[0]), box.MinimumImage(b[1] - a[1]), box.MinimumImage(b[2] - a[2])}
+			r := math.Sqrt(float64(d[0]*d[0] + d[1]*d[1] + d[2]*d[2]))
+			fx, fy, fz, _ := table.Correction(d[0], d[1], d[2])
+			for c, f := range [3]float32{fx, fy, fz} {
+				newton[c] += float64(d[c]) / (r * r * r)
+				ewald[c] += float64(d[c])/(r*r*r) + float64(f)
+			}
+		}
+		for c := range ewald {
+			if math.Abs(ewald[c]) > 1e-3 {
+				t.Errorf("body %d: Ewald force %v, want zero (minimum image %v)", i, ewald, newton)
+				break
+			}
+		}
+	}
+}
+
+// the correction is odd in every coordinate, so a body and its mirror image
+// feel opposite forces
+func TestEwaldCorrectionOdd(t *testing.T) {
+	table := NewEwaldTable(4, 16)
+	fx, fy, fz, pot := table.Correction(0.7, -0.3, 1.1)
+	gx, gy, gz, mirrorPot := table.Correction(-0.7, 0.3, -1.1)
+	if fx != -gx || fy != -gy || fz != -gz || pot != mirrorPot {
+		t.Fatalf("corrections (%g, %g, %g) %g and (%g, %g, %g) %g", fx, fy, fz, pot, gx, gy, gz, mirrorPot)
+	}
+}
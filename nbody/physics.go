@@ -0,0 +1,27 @@
+package nbody
+
+// Physics collects what the force computation depends on besides the bodies
+type Physics struct {
+	Kernel SofteningKernel
+	Box    Box         // Periodic box, zero for open space
+	Ewald  *EwaldTable // Long range periodic correction, nil for minimum image forces only
+	// Analytic potential acting on every body, nil for none
+	External ExternalPotential
+	// Pairwise interaction replacing gravity, nil for Newtonian gravity
+	// softened by Kernel. The Ewald correction only applies to gravity.
+	Law ForceLaw
+}
+
+// return open space physics with the given softening kernel
+func NewPhysics(kernel SofteningKernel) *Physics {
+	return &Physics{Kernel: kernel}
+}
+
+// separation from body a to body b, the nearest image in a periodic box
+func (p *Physics) separation(a, b *Body) (dx, dy, dz float32) {
+	dx, dy, dz = b.x-a.x, b.y-a.y, b.z-a.z
+	if p.Box.Periodic() {
+		dx, dy, dz = p.Box.MinimumImage(dx), p.Box.MinimumImage(dy), p.Box.MinimumImage(dz)
+	}
+	return dx, dy, dz
+}
@@ -0,0 +1,334 @@
+package nbody
+
+import (
+	"fmt"
+	"math"
+)
+
+// ParticleMesh computes accelerations on a grid: masses are assigned to the
+// grid with cloud-in-cell weights, Poisson's equation is solved with FFTs
+// and the mesh forces are interpolated back to the bodies with the same
+// weights. In a periodic box the grid covers the box, in open space it is
+// fitted to the bodies every solve and zero padded to twice its size so
+// the images of the FFT do not interact. The grid spacing acts as the
+// softening length and the softening kernel is not used.
+//
+// Every pass works on a range of grid points, lines or bodies so it can be
+// split into tasks, the passes must run in the order of Solve.
+type ParticleMesh struct {
+	cells int // Grid points per side covered by bodies
+	mesh  int // Grid points per side of the FFT, cells or 2*cells for open space
+	box   Box
+	plan  *fftPlan
+
+	origin  [3]float32 // Position of grid point (0, 0, 0)
+	spacing float32
+	scale   float64 // Factor of the Green's function for the current spacing
+
+	partial    [][]float64  // cells³ masses assigned by each part
+	grid       []complex128 // mesh³ masses, then potentials
+	green      []float64    // mesh³ Green's function in Fourier space, normalized
+	fx, fy, fz []float32    // cells³ mesh accelerations
+}
+
+// return a particle mesh with the given number of grid points per side, a
+// power of two, for the box (zero for open space)
+func NewParticleMesh(cells int, box Box) (*ParticleMesh, error) {
+	if cells < 4 {
+		return nil, fmt.Errorf("nbody: particle mesh needs at least 4 cells per side, got %d", cells)
+	}
+	mesh := cells
+	if !box.Periodic() {
+		mesh = 2 * cells
+	}
+	plan, err := newFFTPlan(mesh)
+	if err != nil {
+		return nil, err
+	}
+
+	pm := &ParticleMesh{
+		cells: cells,
+		mesh:  mesh,
+		box:   box,
+		plan:  plan,
+		grid:  make([]complex128, mesh*mesh*mesh),
+		green: make([]float64, mesh*mesh*mesh),
+		fx:    make([]float32, cells*cells*cells),
+		fy:    make([]float32, cells*cells*cells),
+		fz:    make([]float32, cells*cells*cells),
+	}
+	if box.Periodic() {
+		pm.periodicGreen()
+	} else {
+		pm.isolatedGreen()
+	}
+	return pm, nil
+}
+
+// -4π/k² for a unit grid spacing, the mean density does not contribute
+func (pm *ParticleMesh) periodicGreen() {
+	n := pm.mesh
+	norm := 1 / float64(n*n*n)
+	for p := range pm.green {
+		var k2 float64
+		for _, m := range pm.coordinates(p, n) {
+			if m > n/2 {
+				m -= n
+			}
+			k := 2 * math.Pi * float64(m) / float64(n)
+			k2 += k * k
+		}
+		if k2 > 0 {
+			pm.green[p] = -4 * math.Pi / k2 * norm
+		}
+	}
+}
+
+// transform of -1/r for a unit grid spacing on the padded grid, distances
+// wrap at half the padded grid so every pair of points within the covered
+// cells sees its true separation
+func (pm *ParticleMesh) isolatedGreen() {
+	n := pm.mesh
+	norm := 1 / float64(n*n*n)
+	for p := range pm.grid {
+		var r2 float64
+		for _, m := range pm.coordinates(p, n) {
+			if m > n/2 {
+				m -= n
+			}
+			r2 += float64(m * m)
+		}
+		if r2 == 0 {
+			r2 = 1 // ONE GRID SPACING AT THE ORIGIN, THE SELF FORCE VANISHES ANYWAY
+		}
+		pm.grid[p] = complex(-1/math.Sqrt(r2), 0)
+	}
+
+	for axis := 0; axis < 3; axis++ {
+		pm.Transform(axis, 0, pm.Lines(), false)
+	}
+	for p, g := range pm.grid {
+		pm.green[p] = real(g) * norm // -1/r IS EVEN SO ITS TRANSFORM IS REAL
+	}
+}
+
+// grid coordinates of point p of a grid with n points per side
+func (pm *ParticleMesh) coordinates(p, n int) [3]int {
+	return [3]int{p / (n * n), p / n % n, p % n}
+}
+
+// Cells returns the number of grid points per side covered by bodies
+func (pm *ParticleMesh) Cells() int {
+	return pm.cells
+}
+
+// Points returns the number of points of the FFT grid
+func (pm *ParticleMesh) Points() int {
+	return len(pm.grid)
+}
+
+// FieldPoints returns the number of points of the acceleration grid
+func (pm *ParticleMesh) FieldPoints() int {
+	return len(pm.fx)
+}
+
+// Lines returns the number of one dimensional FFTs along every axis
+func (pm *ParticleMesh) Lines() int {
+	return pm.mesh * pm.mesh
+}
+
+// Fit places the grid for the bodies and clears the masses of parts
+// independent mass assignments
+func (pm *ParticleMesh) Fit(bodies []*Body, numBodies, parts int) {
+	pm.FitBounds(Bounds(bodies, 0, numBodies), parts)
+}
+
+// FitBounds places the grid for bodies inside bounds, which only matters in
+// open space, and clears the masses of parts independent mass assignments
+func (pm *ParticleMesh) FitBounds(bounds BoundingBox, parts int) {
+	if pm.box.Periodic() {
+		pm.spacing = pm.box.Size / float32(pm.cells)
+		pm.origin = [3]float32{-pm.box.Size / 2, -pm.box.Size / 2, -pm.box.Size / 2}
+	} else {
+		extent := bounds.Extent()
+		if extent <= 0 {
+			extent = 1
+		}
+
+		// ONE EMPTY GRID POINT ON EITHER SIDE KEEPS THE CLOUDS AND THE
+		// DIFFERENCES OF THE POTENTIAL INSIDE THE COVERED CELLS
+		pm.spacing = extent / float32(pm.cells-3)
+		for c := range bounds.Min {
+			pm.origin[c] = bounds.Min[c] - pm.spacing
+		}
+	}
+	// BOTH GREEN'S FUNCTIONS ARE FOR MASSES ON A UNIT SPACING
+	pm.scale = 1 / float64(pm.spacing)
+
+	if len(pm.partial) != parts {
+		pm.partial = make([][]float64, parts)
+		for part := range pm.partial {
+			pm.partial[part] = make([]float64, len(pm.fx))
+		}
+	}
+	for _, masses := range pm.partial {
+		for p := range masses {
+			masses[p] = 0
+		}
+	}
+}
+
+// cloud-in-cell cell and weights of a position along one axis
+func (pm *ParticleMesh) cloud(x float32, c int) (int, float32) {
+	u := (x - pm.origin[c]) / pm.spacing
+	cell := int(math.Floor(float64(u)))
+	if pm.box.Periodic() {
+		// ROUNDING CAN PUT A WRAPPED POSITION ON THE FAR EDGE
+		cell = (cell%pm.cells + pm.cells) % pm.cells
+	}
+	return cell, u - float32(math.Floor(float64(u)))
+}
+
+// the 8 grid points around a body and their cloud-in-cell weights
+func (pm *ParticleMesh) neighbours(b *Body) (points [8]int, weights [8]float32) {
+	var cell [3]int
+	var frac [3]float32
+	for c, x := range [3]float32{b.x, b.y, b.z} {
+		cell[c], frac[c] = pm.cloud(x, c)
+	}
+
+	for corner := 0; corner < 8; corner++ {
+		w := float32(1)
+		var idx [3]int
+		for c := 0; c < 3; c++ {
+			idx[c] = cell[c]
+			if corner&(1<<c) != 0 {
+				w *= frac[c]
+				idx[c]++
+			} else {
+				w *= 1 - frac[c]
+			}
+			if idx[c] >= pm.cells {
+				idx[c] -= pm.cells
+			}
+		}
+		points[corner] = (idx[0]*pm.cells+idx[1])*pm.cells + idx[2]
+		weights[corner] = w
+	}
+	return points, weights
+}
+
+// Assign adds the masses of the bodies [start, end) to the grid of part
+func (pm *ParticleMesh) Assign(part int, bodies []*Body, start, end int) {
+	masses := pm.partial[part]
+	for i := start; i < end; i++ {
+		points, weights := pm.neighbours(bodies[i])
+		for corner, p := range points {
+			masses[p] += float64(weights[corner] * bodies[i].mass)
+		}
+	}
+}
+
+// Gather sums the masses of all parts into the FFT grid points [start, end)
+func (pm *ParticleMesh) Gather(start, end int) {
+	for p := start; p < end; p++ {
+		idx := pm.coordinates(p, pm.mesh)
+		if idx[0] >= pm.cells || idx[1] >= pm.cells || idx[2] >= pm.cells {
+			pm.grid[p] = 0 // PADDING
+			continue
+		}
+
+		cell := (idx[0]*pm.cells+idx[1])*pm.cells + idx[2]
+		var mass float64
+		for _, masses := range pm.partial {
+			mass += masses[cell]
+		}
+		pm.grid[p] = complex(mass, 0)
+	}
+}
+
+// Transform runs the FFTs of the lines [start, end) along axis (0, 1 or 2)
+func (pm *ParticleMesh) Transform(axis, start, end int, inverse bool) {
+	n := pm.mesh
+	stride := [3]int{n * n, n, 1}[axis]
+	line := make([]complex128, n)
+	for l := start; l < end; l++ {
+		// THE LINE STARTS AT THE POINT WITH COORDINATE 0 ALONG axis
+		a, b := l/n, l%n
+		var first int
+		switch axis {
+		case 0:
+			first = a*n + b
+		case 1:
+			first = a*n*n + b
+		default:
+			first = (a*n + b) * n
+		}
+
+		for i := range line {
+			line[i] = pm.grid[first+i*stride]
+		}
+		pm.plan.transform(line, inverse)
+		for i := range line {
+			pm.grid[first+i*stride] = line[i]
+		}
+	}
+}
+
+// Convolve multiplies the transformed masses of the points [start, end) by
+// the Green's function
+func (pm *ParticleMesh) Convolve(start, end int) {
+	for p := start; p < end; p++ {
+		pm.grid[p] *= complex(pm.green[p]*pm.scale, 0)
+	}
+}
+
+// Differentiate computes the mesh accelerations of the points [start, end)
+// from central differences of the potential. Neighbours wrap around the FFT
+// grid, which is the periodic box or, in open space, lands on the padding
+// next to the covered cells where the potential is still exact.
+func (pm *ParticleMesh) Differentiate(start, end int) {
+	n := pm.mesh
+	potential := func(i, j, k int) float64 {
+		return real(pm.grid[(((i+n)%n)*n+(j+n)%n)*n+(k+n)%n])
+	}
+
+	factor := -1 / (2 * float64(pm.spacing))
+	for p := start; p < end; p++ {
+		idx := pm.coordinates(p, pm.cells)
+		i, j, k := idx[0], idx[1], idx[2]
+		pm.fx[p] = float32(factor * (potential(i+1, j, k) - potential(i-1, j, k)))
+		pm.fy[p] = float32(factor * (potential(i, j+1, k) - potential(i, j-1, k)))
+		pm.fz[p] = float32(factor * (potential(i, j, k+1) - potential(i, j, k-1)))
+	}
+}
+
+// Interpolate sets the acceleration of a body from the mesh accelerations
+func (pm *ParticleMesh) Interpolate(id int, bodies []*Body) {
+	points, weights := pm.neighbours(bodies[id])
+	var ax, ay, az float32
+	for corner, p := range points {
+		ax += weights[corner] * pm.fx[p]
+		ay += weights[corner] * pm.fy[p]
+		az += weights[corner] * pm.fz[p]
+	}
+	bodies[id].ax = ax
+	bodies[id].ay = ay
+	bodies[id].az = az
+}
+
+// Solve runs every pass over the whole grid, afterwards Interpolate gives
+// the acceleration of any of the bodies
+func (pm *ParticleMesh) Solve(bodies []*Body, numBodies int) {
+	pm.Fit(bodies, numBodies, 1)
+	pm.Assign(0, bodies, 0, numBodies)
+	pm.Gather(0, pm.Points())
+	for axis := 0; axis < 3; axis++ {
+		pm.Transform(axis, 0, pm.Lines(), false)
+	}
+	pm.Convolve(0, pm.Points())
+	for axis := 0; axis < 3; axis++ {
+		pm.Transform(axis, 0, pm.Lines(), true)
+	}
+	pm.Differentiate(0, pm.FieldPoints())
+}
@@ -0,0 +1,51 @@
+package nbody
+
+import (
+	"math"
+	"testing"
+)
+
+// away from the clouds of a few cells the mesh accelerations are those of
+// the direct sum
+func TestParticleMeshDirect(t *testing.T) {
+	mesh, err := NewParticleMesh(32, Box{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A HEAVY BODY AND LIGHT ONES AT SEVERAL CELLS (0.069) AROUND IT, THE
+	// CORNERS FIX THE BOUNDS TO [-1, 1]³
+	states := []BodyState{
+		{X: 0.05, Y: -0.02, Z: 0.01, Mass: 10},
+		{X: -1, Y: -1, Z: -1, Mass: 1e-6},
+		{X: 1, Y: 1, Z: 1, Mass: 1e-6},
+		{X: 0.35, Mass: 1e-6},
+		{Y: -0.6, Z: 0.2, Mass: 1e-6},
+		{X: -0.5, Y: 0.5, Z: 0.5, Mass: 1e-6},
+		{X: 0.8, Y: -0.3, Z: -0.7, Mass: 1e-6},
+	}
+	// THE ERROR FALLS WITH THE SEPARATION: 4, 9, 12 AND 17 CELLS
+	tolerance := []float64{3: 0.1, 4: 0.02, 5: 0.01, 6: 0.01}
+	bodies := make([]*Body, len(states))
+	for i, state := range states {
+		bodies[i] = NewBodyFromState(state)
+	}
+	direct := make([][3]float32, len(bodies))
+	physics := NewPhysics(NoSoftening{})
+	for i := range bodies {
+		ComputeBodyAcceleration(i, bodies, len(bodies), physics)
+		direct[i] = [3]float32{bodies[i].ax, bodies[i].ay, bodies[i].az}
+	}
+
+	mesh.Solve(bodies, len(bodies))
+	for i := 3; i < len(bodies); i++ {
+		mesh.Interpolate(i, bodies)
+		b := bodies[i]
+		want := direct[i]
+		diff := math.Sqrt(float64((b.ax-want[0])*(b.ax-want[0]) + (b.ay-want[1])*(b.ay-want[1]) + (b.az-want[2])*(b.az-want[2])))
+		norm := math.Sqrt(float64(want[0]*want[0] + want[1]*want[1] + want[2]*want[2]))
+		if diff > tolerance[i]*norm {
+			t.Errorf("body %d: mesh acceleration (%g, %g, %g), direct %v, error %.1f%%",
+				i, b.ax, b.ay, b.az, want, 100*diff/norm)
+		}
+	}
+}
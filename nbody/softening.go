@@ -0,0 +1,119 @@
+package nbody
+
+import (
+	"fmt"
+	"math"
+)
+
+// SofteningKernel smooths the inverse-square force at small separations
+type SofteningKernel interface {
+	Name() string
+	// Softening length, the Plummer-equivalent length for the spline kernel
+	Length() float32
+	// Acceleration per unit mass and unit separation at squared distance r2,
+	// the acceleration from a body of mass m at separation d is m*d*factor
+	ForceFactor(r2 float32) float32
+	// Potential of a unit mass at squared distance r2 (negative)
+	Potential(r2 float64) float64
+}
+
+// Plummer softening adds the squared softening length to r²
+type Plummer struct {
+	Eps float32
+}
+
+func (p Plummer) Name() string    { return "plummer" }
+func (p Plummer) Length() float32 { return p.Eps }
+
+func (p Plummer) ForceFactor(r2 float32) float32 {
+	invrDist := float32(1.0 / math.Pow(float64(r2+p.Eps*p.Eps), 0.5))
+	return invrDist * invrDist * invrDist
+}
+
+func (p Plummer) Potential(r2 float64) float64 {
+	return -1 / math.Sqrt(r2+float64(p.Eps)*float64(p.Eps))
+}
+
+// Spline is the cubic spline (Monaghan) kernel, the force is exactly
+// Newtonian beyond the support H = 2.8 Eps, where Eps is the
+// Plummer-equivalent softening length
+type Spline struct {
+	Eps float32
+}
+
+func (s Spline) Name() string    { return "spline" }
+func (s Spline) Length() float32 { return s.Eps }
+
+func (s Spline) ForceFactor(r2 float32) float32 {
+	h := 2.8 * float64(s.Eps)
+	r := math.Sqrt(float64(r2))
+	if r >= h {
+		if r == 0 {
+			return 0
+		}
+		return float32(1 / (r * r * r))
+	}
+
+	u := r / h
+	h3 := 1 / (h * h * h)
+	if u < 0.5 {
+		return float32(h3 * (10.666666666667 + u*u*(32.0*u-38.4)))
+	}
+	return float32(h3 * (21.333333333333 - 48.0*u + 38.4*u*u - 10.666666666667*u*u*u - 0.066666666667/(u*u*u)))
+}
+
+func (s Spline) Potential(r2 float64) float64 {
+	h := 2.8 * float64(s.Eps)
+	r := math.Sqrt(r2)
+	if r >= h {
+		if r == 0 {
+			return 0
+		}
+		return -1 / r
+	}
+
+	u := r / h
+	if u < 0.5 {
+		return (-2.8 + u*u*(5.333333333333+u*u*(6.4*u-9.6))) / h
+	}
+	return (-3.2 + 0.066666666667/u + u*u*(10.666666666667+u*(-16.0+u*(9.6-2.133333333333*u)))) / h
+}
+
+// NoSoftening is the bare inverse-square law, coincident bodies exert no
+// force on each other
+type NoSoftening struct{}
+
+func (NoSoftening) Name() string    { return "none" }
+func (NoSoftening) Length() float32 { return 0 }
+
+func (NoSoftening) ForceFactor(r2 float32) float32 {
+	if r2 == 0 {
+		return 0
+	}
+	invrDist := float32(1.0 / math.Pow(float64(r2), 0.5))
+	return invrDist * invrDist * invrDist
+}
+
+func (NoSoftening) Potential(r2 float64) float64 {
+	if r2 == 0 {
+		return 0
+	}
+	return -1 / math.Sqrt(r2)
+}
+
+// return the softening kernel with the given name ("plummer", "spline" or
+// "none") and softening length
+func NewSofteningKernel(name string, length float32) (SofteningKernel, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("invalid softening length %g", length)
+	}
+	switch name {
+	case "plummer":
+		return Plummer{Eps: length}, nil
+	case "spline":
+		return Spline{Eps: length}, nil
+	case "none":
+		return NoSoftening{}, nil
+	}
+	return nil, fmt.Errorf("unknown softening kernel %q", name)
+}
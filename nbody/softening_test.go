@@ -0,0 +1,54 @@
+package nbody
+
+import (
+	"math"
+	"testing"
+)
+
+var testKernels = []SofteningKernel{Plummer{Eps: 0.1}, Spline{Eps: 0.1}, NoSoftening{}}
+
+// the acceleration of every kernel is minus the derivative of its
+// potential, r * ForceFactor(r²) = dΦ/dr
+func TestKernelForceIsPotentialGradient(t *testing.T) {
+	const h = 1e-6
+	for _, kernel := range testKernels {
+		for _, r := range []float64{0.01, 0.05, 0.13, 0.2, 0.27, 0.3, 0.5, 2} {
+			dPhi := (kernel.Potential((r+h)*(r+h)) - kernel.Potential((r-h)*(r-h))) / (2 * h)
+			force := r * float64(kernel.ForceFactor(float32(r*r)))
+			if math.Abs(force-dPhi) > 1e-4*math.Abs(dPhi) {
+				t.Errorf("%s at r = %g: force %g, dPhi/dr %g", kernel.Name(), r, force, dPhi)
+			}
+		}
+	}
+}
+
+// the spline force and potential are Newtonian beyond 2.8 eps
+func TestSplineNewtonian(t *testing.T) {
+	s := Spline{Eps: 0.1}
+	for _, r := range []float64{0.28, 0.3, 1, 10} {
+		if f, want := s.ForceFactor(float32(r*r)), 1/(r*r*r); math.Abs(float64(f)-want) > 1e-6*want {
+			t.Errorf("force factor at r = %g is %g, want %g", r, f, want)
+		}
+		if phi := s.Potential(r * r); math.Abs(phi+1/r) > 1e-12/r {
+			t.Errorf("potential at r = %g is %g, want %g", r, phi, -1/r)
+		}
+	}
+	if f := s.ForceFactor(0.2 * 0.2); f >= 1/(0.2*0.2*0.2) {
+		t.Errorf("force factor inside the support %g is not softened", f)
+	}
+}
+
+func TestNewSofteningKernel(t *testing.T) {
+	for _, name := range []string{"plummer", "spline", "none"} {
+		kernel, err := NewSofteningKernel(name, 0.1)
+		if err != nil || kernel.Name() != name {
+			t.Errorf("%s: kernel %v, err %v", name, kernel, err)
+		}
+	}
+	if _, err := NewSofteningKernel("gaussian", 0.1); err == nil {
+		t.Error("an unknown kernel was accepted")
+	}
+	if _, err := NewSofteningKernel("plummer", -1); err == nil {
+		t.Error("a negative length was accepted")
+	}
+}
@@ -0,0 +1,42 @@
+package nbody
+
+// BodyState is a copy of the state of a body that can be read and built
+// outside of this package
+type BodyState struct {
+	ID         int
+	X, Y, Z    float32
+	VX, VY, VZ float32
+	AX, AY, AZ float32 // Acceleration from the last force computation
+	Mass       float32
+	Level      int // Block timestep level
+	Radius     float32
+	Charge     float32
+}
+
+// State returns a copy of the state of a body
+func (b *Body) State() BodyState {
+	return BodyState{
+		ID: b.id,
+		X:  b.x, Y: b.y, Z: b.z,
+		VX: b.vx, VY: b.vy, VZ: b.vz,
+		AX: b.ax, AY: b.ay, AZ: b.az,
+		Mass:   b.mass,
+		Level:  b.level,
+		Radius: b.radius,
+		Charge: b.charge,
+	}
+}
+
+// return a new body with the given state
+func NewBodyFromState(state BodyState) *Body {
+	return &Body{
+		id: state.ID,
+		x:  state.X, y: state.Y, z: state.Z,
+		vx: state.VX, vy: state.VY, vz: state.VZ,
+		ax: state.AX, ay: state.AY, az: state.AZ,
+		mass:   state.Mass,
+		level:  state.Level,
+		radius: state.Radius,
+		charge: state.Charge,
+	}
+}
@@ -0,0 +1,39 @@
+package nbody
+
+import "math"
+
+// TimestepController chooses a global timestep from the largest
+// acceleration, dt = Eta * sqrt(Softening / |a|max), clamped to
+// [DtMin, DtMax]
+type TimestepController struct {
+	Eta       float32 // Accuracy parameter, smaller is more accurate
+	Softening float32 // Softening length
+	DtMin     float32
+	DtMax     float32
+}
+
+// return the timestep for the given largest acceleration magnitude
+func (c TimestepController) Timestep(maxAcceleration float32) float32 {
+	if maxAcceleration <= 0 {
+		return c.DtMax
+	}
+	dt := c.Eta * float32(math.Sqrt(float64(c.Softening/maxAcceleration)))
+	if dt < c.DtMin {
+		return c.DtMin
+	} else if dt > c.DtMax {
+		return c.DtMax
+	}
+	return dt
+}
+
+// largest acceleration magnitude of the bodies in [start, end)
+func MaxAcceleration(bodies []*Body, start, end int) float32 {
+	var max float32
+	for i := start; i < end; i++ {
+		b := bodies[i]
+		if acc := b.ax*b.ax + b.ay*b.ay + b.az*b.az; acc > max {
+			max = acc
+		}
+	}
+	return float32(math.Sqrt(float64(max)))
+}
@@ -0,0 +1,38 @@
+package nbody
+
+import "testing"
+
+func TestTimestepClamp(t *testing.T) {
+	c := TimestepController{Eta: 0.5, Softening: 1, DtMin: 0.01, DtMax: 0.1}
+	tests := []struct {
+		acceleration, want float32
+	}{
+		{0, 0.1},    // NO ACCELERATION TAKES THE LONGEST STEP
+		{1, 0.1},    // 0.5 IS ABOVE DtMax
+		{100, 0.05}, // 0.5 * sqrt(1 / 100)
+		{1e6, 0.01}, // 0.0005 IS BELOW DtMin
+		{-1, 0.1},   // NOT A MAGNITUDE
+	}
+	for _, test := range tests {
+		if dt := c.Timestep(test.acceleration); dt != test.want {
+			t.Errorf("Timestep(%g) = %g, want %g", test.acceleration, dt, test.want)
+		}
+	}
+}
+
+func TestMaxAcceleration(t *testing.T) {
+	bodies := []*Body{
+		NewBodyFromState(BodyState{AX: 3, AY: 4}),
+		NewBodyFromState(BodyState{AZ: -12}),
+		NewBodyFromState(BodyState{AX: 1}),
+	}
+	if max := MaxAcceleration(bodies, 0, 3); max != 12 {
+		t.Errorf("largest acceleration %g, want 12", max)
+	}
+	if max := MaxAcceleration(bodies, 2, 3); max != 1 {
+		t.Errorf("largest acceleration of the last body %g, want 1", max)
+	}
+	if max := MaxAcceleration(bodies, 1, 1); max != 0 {
+		t.Errorf("largest acceleration of no bodies %g, want 0", max)
+	}
+}
@@ -0,0 +1,42 @@
+package render
+
+import (
+	"strings"
+
+	"proj3/snapshot"
+)
+
+// braille dot bits of a 2x4 character cell, indexed by [row][column]
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// Braille draws frame f as cols x rows unicode braille characters, a dot is
+// raised wherever at least one body projects into it. Only the view and
+// bounds of the renderer are used.
+func (r *Renderer) Braille(f *snapshot.Frame, index, cols, rows int) string {
+	cells := make([]rune, cols*rows)
+	w, h := 2*cols, 4*rows
+	for i := 0; i < f.N; i++ {
+		x, y, z := position(f, i)
+		u, v := r.project(x, y, z, index)
+		px := int((u + 1) / 2 * float64(w))
+		py := int((1 - (v+1)/2) * float64(h))
+		if px < 0 || px >= w || py < 0 || py >= h {
+			continue
+		}
+		cells[(py/4)*cols+px/2] |= brailleDots[py%4][px%2]
+	}
+
+	var sb strings.Builder
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			sb.WriteRune(0x2800 + cells[row*cols+col])
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
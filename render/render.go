@@ -0,0 +1,259 @@
+// Package render draws 2D projections of simulation snapshots to PNG
+// frames and animated GIFs without any dependencies outside the standard
+// library.
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"proj3/snapshot"
+)
+
+// Options controls how frames are drawn
+type Options struct {
+	Width, Height int
+	View          string // "xy", "xz", "yz" or "3d" (rotating orthographic view)
+	// Half width of the plotted box around the origin, 0 fits the bounds to
+	// the bodies of all frames (the python script used 1000)
+	Bounds float64
+	Color  string  // Color bodies by "cluster", "speed" or "mass"
+	Spin   float64 // Rotation per frame of the 3d view in degrees
+	Tilt   float64 // Elevation of the 3d view in degrees
+}
+
+// DefaultOptions returns the options used by the editor render subcommand
+func DefaultOptions() Options {
+	return Options{Width: 800, Height: 800, View: "xy", Color: "cluster", Spin: 2, Tilt: 30}
+}
+
+const (
+	background = 0
+	axes       = 1
+	clusters   = 2 // First of the three cluster colors
+	ramp       = 5 // First of the rampSize colors of the speed/mass ramp
+	rampSize   = 64
+)
+
+// palette shared by every frame so the GIF needs no local color tables
+var palette = func() color.Palette {
+	p := color.Palette{
+		color.RGBA{0x10, 0x10, 0x18, 0xff},
+		color.RGBA{0x60, 0x60, 0x70, 0xff},
+		color.RGBA{0x1f, 0x77, 0xb4, 0xff},
+		color.RGBA{0xff, 0x7f, 0x0e, 0xff},
+		color.RGBA{0x2c, 0xa0, 0x2c, 0xff},
+	}
+	// BLUE -> CYAN -> YELLOW -> RED
+	stops := [][3]float64{{0x30, 0x30, 0xc0}, {0x20, 0xc0, 0xd0}, {0xf0, 0xe0, 0x30}, {0xe0, 0x30, 0x20}}
+	for i := 0; i < rampSize; i++ {
+		t := float64(i) / float64(rampSize-1) * float64(len(stops)-1)
+		k := int(t)
+		if k == len(stops)-1 {
+			k--
+		}
+		t -= float64(k)
+		var c [3]uint8
+		for j := range c {
+			c[j] = uint8(stops[k][j] + t*(stops[k+1][j]-stops[k][j]))
+		}
+		p = append(p, color.RGBA{c[0], c[1], c[2], 0xff})
+	}
+	return p
+}()
+
+// Renderer draws frames with bounds and color scale fixed across an
+// animation
+type Renderer struct {
+	opts            Options
+	bounds          float64
+	lowVal, highVal float64
+}
+
+// return a new renderer, bounds and the color scale are fitted to frames
+func New(opts Options, frames []*snapshot.Frame) (*Renderer, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, errors.New("render: image size must be positive")
+	}
+	switch opts.View {
+	case "xy", "xz", "yz", "3d":
+	default:
+		return nil, fmt.Errorf("render: unknown view %q", opts.View)
+	}
+	switch opts.Color {
+	case "cluster", "speed", "mass":
+	default:
+		return nil, fmt.Errorf("render: unknown coloring %q", opts.Color)
+	}
+
+	r := &Renderer{opts: opts, bounds: opts.Bounds, lowVal: math.Inf(1), highVal: math.Inf(-1)}
+	for _, f := range frames {
+		if f.Column("x") < 0 || f.Column("y") < 0 || f.Column("z") < 0 {
+			return nil, errors.New("render: snapshot needs the x, y and z fields")
+		}
+		if opts.Bounds <= 0 {
+			for i := 0; i < f.N; i++ {
+				x, y, z := position(f, i)
+				r.bounds = math.Max(r.bounds, math.Max(math.Abs(x), math.Max(math.Abs(y), math.Abs(z))))
+			}
+		}
+		if opts.Color != "cluster" {
+			for i := 0; i < f.N; i++ {
+				v, ok := value(f, i, opts.Color)
+				if !ok {
+					return nil, fmt.Errorf("render: snapshot has no %s field", opts.Color)
+				}
+				r.lowVal = math.Min(r.lowVal, v)
+				r.highVal = math.Max(r.highVal, v)
+			}
+		}
+	}
+	if r.bounds <= 0 {
+		r.bounds = 1
+	}
+	if opts.Bounds <= 0 {
+		r.bounds *= 1.05 // MARGIN AROUND THE OUTERMOST BODY
+	}
+	if opts.View == "3d" {
+		r.bounds *= math.Sqrt(3) // ANY ROTATION OF THE BOX STAYS IN VIEW
+	}
+	return r, nil
+}
+
+func position(f *snapshot.Frame, i int) (x, y, z float64) {
+	return float64(f.Value(i, f.Column("x"))), float64(f.Value(i, f.Column("y"))), float64(f.Value(i, f.Column("z")))
+}
+
+// value of the coloring quantity of body i
+func value(f *snapshot.Frame, i int, quantity string) (float64, bool) {
+	if c := f.Column(quantity); c >= 0 {
+		return float64(f.Value(i, c)), true
+	}
+	if quantity == "speed" && f.Column("vx") >= 0 && f.Column("vy") >= 0 && f.Column("vz") >= 0 {
+		vx, vy, vz := f.Value(i, f.Column("vx")), f.Value(i, f.Column("vy")), f.Value(i, f.Column("vz"))
+		return math.Sqrt(float64(vx*vx + vy*vy + vz*vz)), true
+	}
+	return 0, false
+}
+
+// project a position to screen coordinates in [-1, 1]
+func (r *Renderer) project(x, y, z float64, index int) (float64, float64) {
+	switch r.opts.View {
+	case "xz":
+		return x / r.bounds, z / r.bounds
+	case "yz":
+		return y / r.bounds, z / r.bounds
+	case "3d":
+		spin := float64(index) * r.opts.Spin * math.Pi / 180
+		tilt := r.opts.Tilt * math.Pi / 180
+		// ROTATE ABOUT Z, THEN TILT THE VIEW TOWARDS THE XY PLANE
+		u := x*math.Cos(spin) - y*math.Sin(spin)
+		depth := x*math.Sin(spin) + y*math.Cos(spin)
+		v := z*math.Cos(tilt) + depth*math.Sin(tilt)
+		return u / r.bounds, v / r.bounds
+	default:
+		return x / r.bounds, y / r.bounds
+	}
+}
+
+// Render draws frame f, index is the position of the frame in the
+// animation and sets the rotation of the 3d view
+func (r *Renderer) Render(f *snapshot.Frame, index int) *image.Paletted {
+	w, h := r.opts.Width, r.opts.Height
+	img := image.NewPaletted(image.Rect(0, 0, w, h), palette)
+
+	// BORDER OF THE PLOTTED BOX
+	for px := 0; px < w; px++ {
+		img.SetColorIndex(px, 0, axes)
+		img.SetColorIndex(px, h-1, axes)
+	}
+	for py := 0; py < h; py++ {
+		img.SetColorIndex(0, py, axes)
+		img.SetColorIndex(w-1, py, axes)
+	}
+
+	idColumn := f.Column("id")
+	for i := 0; i < f.N; i++ {
+		var c uint8
+		if r.opts.Color == "cluster" {
+			id := i
+			if idColumn >= 0 {
+				id = int(f.Value(i, idColumn))
+			}
+			c = clusters + uint8(id%3) // SAME CLUSTERS AS nbody.InitPositionsAndVelocities
+		} else {
+			v, _ := value(f, i, r.opts.Color)
+			t := 0.0
+			if r.highVal > r.lowVal {
+				t = (v - r.lowVal) / (r.highVal - r.lowVal)
+			}
+			c = ramp + uint8(t*(rampSize-1))
+		}
+
+		x, y, z := position(f, i)
+		u, v := r.project(x, y, z, index)
+		px := int((u + 1) / 2 * float64(w-1))
+		py := int((1 - (v+1)/2) * float64(h-1))
+		for dy := 0; dy < 2; dy++ {
+			for dx := 0; dx < 2; dx++ {
+				if image.Pt(px+dx, py+dy).In(img.Rect) {
+					img.SetColorIndex(px+dx, py+dy, c)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// WriteGIF renders every frame into an animated GIF played at fps frames
+// per second
+func WriteGIF(w io.Writer, frames []*snapshot.Frame, opts Options, fps int) error {
+	r, err := New(opts, frames)
+	if err != nil {
+		return err
+	}
+	if fps <= 0 {
+		fps = 15
+	}
+
+	anim := &gif.GIF{}
+	for i, f := range frames {
+		anim.Image = append(anim.Image, r.Render(f, i))
+		anim.Delay = append(anim.Delay, 100/fps)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// WritePNGs renders every frame to dir/frame_<index>.png
+func WritePNGs(dir string, frames []*snapshot.Frame, opts Options) error {
+	r, err := New(opts, frames)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for i, f := range frames {
+		file, err := os.Create(filepath.Join(dir, fmt.Sprintf("frame_%04d.png", i)))
+		if err != nil {
+			return err
+		}
+		err = png.Encode(file, r.Render(f, i))
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
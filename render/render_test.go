@@ -0,0 +1,109 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"proj3/snapshot"
+)
+
+// return frames of one body moving along x
+func testFrames(n int) []*snapshot.Frame {
+	frames := make([]*snapshot.Frame, n)
+	for i := range frames {
+		f := snapshot.NewFrame([]string{"id", "x", "y", "z", "mass"}, 2)
+		copy(f.Data, []float32{0, float32(i), 0, 0, 1, 1, -1, 1, 0, 2})
+		f.Step = int64(i)
+		frames[i] = f
+	}
+	return frames
+}
+
+func testOptions() Options {
+	opts := DefaultOptions()
+	opts.Width, opts.Height, opts.Bounds = 64, 48, 4
+	return opts
+}
+
+func TestWriteGIF(t *testing.T) {
+	const frames, fps = 3, 20
+	var out bytes.Buffer
+	if err := WriteGIF(&out, testFrames(frames), testOptions(), fps); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != frames {
+		t.Fatalf("%d images, want %d", len(anim.Image), frames)
+	}
+	for i, img := range anim.Image {
+		if img.Bounds() != image.Rect(0, 0, 64, 48) || anim.Delay[i] != 100/fps {
+			t.Fatalf("image %d is %v with delay %d", i, img.Bounds(), anim.Delay[i])
+		}
+	}
+}
+
+func TestWritePNGs(t *testing.T) {
+	const frames = 2
+	dir := t.TempDir()
+	if err := WritePNGs(dir, testFrames(frames), testOptions()); err != nil {
+		t.Fatal(err)
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "frame_*.png"))
+	if err != nil || len(names) != frames {
+		t.Fatalf("wrote %v, want %d frames", names, frames)
+	}
+	for _, name := range names {
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 64, 48) {
+			t.Fatalf("%s is %v", name, img.Bounds())
+		}
+	}
+}
+
+// the body at the origin is drawn in the center in the color of its cluster
+func TestRenderBody(t *testing.T) {
+	frames := testFrames(1)
+	r, err := New(testOptions(), frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := r.Render(frames[0], 0)
+	if c := img.ColorIndexAt(31, 23); c != clusters {
+		t.Fatalf("center pixel has color %d, want %d", c, clusters)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	bad := []func(*Options){
+		func(opts *Options) { opts.Width = 0 },
+		func(opts *Options) { opts.View = "zx" },
+		func(opts *Options) { opts.Color = "charge" },
+	}
+	for i, change := range bad {
+		opts := testOptions()
+		change(&opts)
+		if _, err := New(opts, testFrames(1)); err == nil {
+			t.Errorf("options %d were accepted: %+v", i, opts)
+		}
+	}
+	noZ := snapshot.NewFrame([]string{"x", "y"}, 1)
+	if _, err := New(testOptions(), []*snapshot.Frame{noZ}); err == nil {
+		t.Error("a frame without z was accepted")
+	}
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"proj3/concurrent"
+	"proj3/nbody"
+)
+
+// SolverAccuracy is the cost and the error of one force solver on the
+// initial bodies of a configuration
+type SolverAccuracy struct {
+	Solver    string
+	Time      time.Duration
+	MeanError float64 // Mean relative error of the accelerations against the direct sum
+	MaxError  float64 // Largest relative error of any body
+}
+
+// CompareSolvers computes the accelerations of the initial bodies of the
+// configuration once with the direct sum and once with the multipole
+// solver of every order, sequentially or with the executor of the mode.
+// The first result is the direct sum.
+func CompareSolvers(config Config, orders []int) []SolverAccuracy {
+	numBodies := config.NBodies
+	bodies := make([]*nbody.Body, numBodies)
+	for i := 0; i < numBodies; i++ {
+		nbody.InitPositionsAndVelocities(i, bodies, numBodies)
+	}
+	physics := newPhysics(config)
+
+	var executor concurrent.ExecutorService
+	chunks := 1
+	if config.Mode != "s" {
+		executor = newExecutor(config)
+		defer executor.Shutdown()
+		chunks = 4 * config.ThreadCount
+	}
+
+	start := time.Now()
+	if executor == nil {
+		for i := 0; i < numBodies; i++ {
+			nbody.ComputeBodyAcceleration(i, bodies, numBodies, physics)
+		}
+	} else {
+		futures := make([]concurrent.Future, numBodies)
+		for i := 0; i < numBodies; i++ {
+			futures[i] = executor.Submit(NewNbodyTask(i, bodies, 0, numBodies, physics, ComputeAcceleration))
+		}
+		for _, f := range futures {
+			f.Get()
+		}
+	}
+	results := []SolverAccuracy{{Solver: "direct", Time: time.Since(start)}}
+
+	reference := make([][3]float32, numBodies)
+	for i := 0; i < numBodies; i++ {
+		reference[i][0], _ = bodies[i].Value("ax")
+		reference[i][1], _ = bodies[i].Value("ay")
+		reference[i][2], _ = bodies[i].Value("az")
+	}
+
+	for _, order := range orders {
+		config.FMMOrder = order
+		solver := newFMMSolver(config, physics, chunks)
+
+		start := time.Now()
+		solver.accelerations(executor, bodies, numBodies, nil)
+		result := SolverAccuracy{Solver: fmt.Sprintf("fmm p=%d", solver.fmm.Order), Time: time.Since(start)}
+
+		for i := 0; i < numBodies; i++ {
+			var diff, norm float64
+			for c, field := range [3]string{"ax", "ay", "az"} {
+				a, _ := bodies[i].Value(field)
+				diff += float64((a - reference[i][c]) * (a - reference[i][c]))
+				norm += float64(reference[i][c] * reference[i][c])
+			}
+			if norm == 0 {
+				continue
+			}
+			err := math.Sqrt(diff / norm)
+			result.MeanError += err / float64(numBodies)
+			result.MaxError = math.Max(result.MaxError, err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// WriteSolverAccuracy writes the results of CompareSolvers as a table
+func WriteSolverAccuracy(w io.Writer, results []SolverAccuracy) error {
+	if _, err := fmt.Fprintf(w, "%-10s %12s %8s %12s %12s\n", "SOLVER", "TIME", "SPEEDUP", "MEAN ERROR", "MAX ERROR"); err != nil {
+		return err
+	}
+	for _, r := range results {
+		_, err := fmt.Fprintf(w, "%-10s %12s %8.2f %12.3e %12.3e\n", r.Solver, r.Time.Round(time.Microsecond),
+			results[0].Time.Seconds()/r.Time.Seconds(), r.MeanError, r.MaxError)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"proj3/concurrent"
+	"proj3/nbody"
+)
+
+// Timestep records the adaptive timestep taken at one step
+type Timestep struct {
+	Step            int
+	Time            float32 // Simulation time at the start of the step
+	Dt              float32
+	MaxAcceleration float32
+}
+
+// return the adaptive timestep controller of the configuration, or nil if
+// every step uses the fixed dt
+func newTimestepController(config Config, dt float32) *nbody.TimestepController {
+	if config.TimestepEta <= 0 {
+		return nil
+	}
+
+	// WITHOUT SOFTENING THE CRITERION STILL NEEDS A LENGTH, OR EVERY STEP WOULD BE DtMin
+	length := newSofteningKernel(config).Length()
+	if length <= 0 {
+		length = config.SofteningLength
+	}
+	if length <= 0 {
+		length = 0.01
+	}
+
+	controller := &nbody.TimestepController{
+		Eta:       config.TimestepEta,
+		Softening: length,
+		DtMin:     config.TimestepMin,
+		DtMax:     config.TimestepMax,
+	}
+	if controller.DtMax <= 0 {
+		controller.DtMax = dt
+	}
+	if controller.DtMin <= 0 || controller.DtMin > controller.DtMax {
+		controller.DtMin = controller.DtMax / 1000
+	}
+	return controller
+}
+
+// return the block timesteps of the configuration, or nil if all bodies
+// share one timestep
+func newBlockTimesteps(config Config, dt float32) *nbody.BlockTimesteps {
+	if config.BlockLevels <= 0 {
+		return nil
+	}
+
+	if config.TimestepEta <= 0 {
+		config.TimestepEta = 0.05
+	}
+	return &nbody.BlockTimesteps{
+		Controller: *newTimestepController(config, dt),
+		Levels:     config.BlockLevels,
+	}
+}
+
+// count the bodies on every block timestep level
+func levelHistogram(blocks *nbody.BlockTimesteps, bodies []*nbody.Body, numBodies int) []int {
+	levels := make([]int, blocks.Levels+1)
+	for i := 0; i < numBodies; i++ {
+		levels[bodies[i].Level()]++
+	}
+	return levels
+}
+
+// callable task returning the largest acceleration of a chunk of bodies
+type maxAccelerationTask struct {
+	bodies     []*nbody.Body
+	start, end int
+}
+
+func (task *maxAccelerationTask) Call() float32 {
+	return nbody.MaxAcceleration(task.bodies, task.start, task.end)
+}
+
+// return the largest acceleration of the bodies
+func parallelMaxAcceleration(executor concurrent.ExecutorService, bodies []*nbody.Body,
+	numBodies, chunks int) float32 {
+	return Reduce(executor, numBodies, chunks, func(start, end int) concurrent.TypedCallable[float32] {
+		return &maxAccelerationTask{bodies: bodies, start: start, end: end}
+	}, 0, func(a, b float32) float32 {
+		if b > a {
+			return b
+		}
+		return a
+	})
+}
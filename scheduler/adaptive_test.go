@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+)
+
+// the limits of the controller default to the fixed timestep and are
+// clamped to an ordered range
+func TestTimestepControllerLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       Config
+		dtMin, dtMax float32
+	}{
+		{"defaults", Config{TimestepEta: 0.1}, 0.01 / 1000, 0.01},
+		{"given", Config{TimestepEta: 0.1, TimestepMin: 0.001, TimestepMax: 0.1}, 0.001, 0.1},
+		{"min above max", Config{TimestepEta: 0.1, TimestepMin: 1, TimestepMax: 0.1}, 0.1 / 1000, 0.1},
+	}
+	for _, test := range tests {
+		c := newTimestepController(test.config, 0.01)
+		if math.Abs(float64(c.DtMin-test.dtMin)) > 1e-9 || c.DtMax != test.dtMax {
+			t.Errorf("%s: dt in [%g, %g], want [%g, %g]", test.name, c.DtMin, c.DtMax, test.dtMin, test.dtMax)
+		}
+	}
+	if newTimestepController(Config{}, 0.01) != nil {
+		t.Error("a controller without TimestepEta")
+	}
+}
+
+// without softening the criterion still has a length, the softening length
+// given or the default one
+func TestTimestepControllerNoSoftening(t *testing.T) {
+	tests := []struct {
+		length, want float32
+	}{
+		{0, 0.01},
+		{0.05, 0.05},
+	}
+	for _, test := range tests {
+		config := Config{TimestepEta: 0.1, SofteningKernel: "none", SofteningLength: test.length}
+		if c := newTimestepController(config, 0.01); c.Softening != test.want {
+			t.Errorf("softening length %g: criterion length %g, want %g", test.length, c.Softening, test.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"proj3/concurrent"
+	"proj3/nbody"
+)
+
+// CollisionEvent is a collision that happened during a run
+type CollisionEvent struct {
+	Step int
+	Time float32
+	nbody.Collision
+}
+
+// return whether the configuration handles collisions
+func collisionsEnabled(config Config) bool {
+	if config.Collisions == "" || config.Collisions == "none" {
+		return false
+	} else if config.Collisions == "merge" || config.Collisions == "bounce" {
+		return true
+	}
+	panic("Invalid collision mode: " + config.Collisions)
+}
+
+// callable task returning the overlapping pairs of a chunk of bodies
+type collisionTask struct {
+	bodies     []*nbody.Body
+	numBodies  int
+	box        nbody.Box
+	start, end int
+}
+
+func (task *collisionTask) Call() [][2]int {
+	var pairs [][2]int
+	for i := task.start; i < task.end; i++ {
+		pairs = nbody.FindBodyCollisions(i, task.bodies, task.numBodies, task.box, pairs)
+	}
+	return pairs
+}
+
+// detect overlapping bodies, on the executor if there is one, and merge or
+// bounce them. It returns the bodies left after merging.
+func collide(config Config, executor concurrent.ExecutorService, chunks int, box nbody.Box,
+	bodies []*nbody.Body, numBodies, step int, simTime float32, stats *Stats) []*nbody.Body {
+	// BODIES NEAR THE START HAVE MORE PAIRS TO CHECK, SO USE SMALL CHUNKS
+	pairs := Reduce(executor, numBodies, chunks, func(start, end int) concurrent.TypedCallable[[][2]int] {
+		return &collisionTask{bodies, numBodies, box, start, end}
+	}, nil, func(a, b [][2]int) [][2]int {
+		return append(a, b...)
+	})
+
+	var events []nbody.Collision
+	if config.Collisions == "merge" {
+		bodies, events = nbody.MergeCollisions(bodies, numBodies, pairs, box)
+	} else {
+		events = nbody.BounceCollisions(bodies, pairs, box)
+	}
+	for _, event := range events {
+		stats.Collisions = append(stats.Collisions, CollisionEvent{step, simTime, event})
+	}
+	return bodies
+}
+
+// WriteCollisionLog writes the collisions of a run as csv
+func WriteCollisionLog(w io.Writer, stats Stats) error {
+	if _, err := fmt.Fprintln(w, "step, time, kind, a, b, mass, x, y, z, vx, vy, vz"); err != nil {
+		return err
+	}
+	for _, c := range stats.Collisions {
+		_, err := fmt.Fprintf(w, "%d, %e, %s, %d, %d, %e, %e, %e, %e, %e, %e, %e\n",
+			c.Step, c.Time, c.Kind, c.A, c.B, c.Mass, c.X, c.Y, c.Z, c.VX, c.VY, c.VZ)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"proj3/concurrent"
+	"proj3/nbody"
+)
+
+// WriteDiagnostics writes the per step diagnostics of a run as csv
+func WriteDiagnostics(w io.Writer, stats Stats) error {
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintln(bw, "step, time, dt, amax"); err != nil {
+		return err
+	}
+	for _, t := range stats.Timesteps {
+		_, err := fmt.Fprintf(bw, "%d, %e, %e, %e\n", t.Step, t.Time, t.Dt, t.MaxAcceleration)
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// GlobalDiagnostic is the global state of the bodies at one step
+type GlobalDiagnostic struct {
+	Step               int
+	Time               float32
+	Kinetic, Potential float64
+	CenterOfMass       [3]float64
+	Momentum           [3]float64
+	Bounds             nbody.BoundingBox
+	MaxAcceleration    float32 // Largest acceleration of the last force computation
+}
+
+// return the global diagnostics of the bodies, every quantity is a
+// reduction
+func globalDiagnostic(executor concurrent.ExecutorService, bodies []*nbody.Body, numBodies int,
+	physics *nbody.Physics, chunks, step int, time float32) GlobalDiagnostic {
+	d := GlobalDiagnostic{Step: step, Time: time}
+	d.Kinetic, d.Potential = parallelEnergy(executor, bodies, numBodies, physics, chunks)
+	moments := parallelMoments(executor, bodies, numBodies, chunks)
+	d.CenterOfMass, d.Momentum = moments.CenterOfMass(), moments.Momentum
+	d.Bounds = parallelBounds(executor, bodies, numBodies, chunks)
+	d.MaxAcceleration = parallelMaxAcceleration(executor, bodies, numBodies, chunks)
+	return d
+}
+
+// WriteGlobalDiagnostics writes the global diagnostics of a run as csv
+func WriteGlobalDiagnostics(w io.Writer, stats Stats) error {
+	bw := bufio.NewWriter(w)
+	_, err := fmt.Fprintln(bw, "step, time, kinetic, potential, total, cx, cy, cz, px, py, pz, "+
+		"xmin, ymin, zmin, xmax, ymax, zmax, amax")
+	if err != nil {
+		return err
+	}
+	for _, d := range stats.Globals {
+		c, p, b := d.CenterOfMass, d.Momentum, d.Bounds
+		_, err := fmt.Fprintf(bw, "%d, %e, %e, %e, %e, %e, %e, %e, %e, %e, %e, %e, %e, %e, %e, %e, %e, %e\n",
+			d.Step, d.Time, d.Kinetic, d.Potential, d.Kinetic+d.Potential, c[0], c[1], c[2], p[0], p[1], p[2],
+			b.Min[0], b.Min[1], b.Min[2], b.Max[0], b.Max[1], b.Max[2], d.MaxAcceleration)
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
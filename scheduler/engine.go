@@ -0,0 +1,204 @@
+package scheduler
+
+import (
+	"proj3/concurrent"
+	"proj3/nbody"
+	"runtime"
+)
+
+// Backend runs the per body work of a step, either on the calling
+// goroutine or as tasks on an executor, see NewSequentialBackend and
+// NewExecutorBackend. Other packages can implement it to run the work
+// elsewhere; the simulation calls it from one goroutine.
+type Backend interface {
+	// Executor running the tasks of the solvers and the collision
+	// detection, nil if they run on the calling goroutine
+	Executor() concurrent.ExecutorService
+	// Number of tasks the solvers and reductions split their work into
+	Chunks() int
+	InitBodies(bodies []*nbody.Body, numBodies int)
+	// Compute the accelerations of the bodies ids (all bodies if ids is nil)
+	Accelerations(bodies []*nbody.Body, numBodies int, physics *nbody.Physics, ids []int)
+	// Compute the accelerations and update the velocities of all bodies
+	Forces(bodies []*nbody.Body, numBodies int, dt float32, physics *nbody.Physics)
+	Integrate(bodies []*nbody.Body, numBodies int, dt float32, box nbody.Box)
+	KickAndIntegrate(bodies []*nbody.Body, numBodies int, dt float32, box nbody.Box)
+	// Resize the executor for a phase of tasks and record how many ran
+	BeginPhase(phase string)
+	EndPhase(tasks int)
+	// Stop the executor if the backend created it
+	Shutdown()
+}
+
+// return the backend running every phase on the simulation goroutine
+func NewSequentialBackend() Backend {
+	return sequentialEngine{}
+}
+
+// return a backend submitting the tasks of every phase to executor, the
+// solvers and reductions split their work for threads workers. The
+// executor is not shut down when the simulation is closed, so it can be
+// shared by several runs.
+func NewExecutorBackend(executor concurrent.ExecutorService, threads int) Backend {
+	if threads <= 0 {
+		threads = runtime.GOMAXPROCS(0)
+	}
+	return &executorEngine{service: executor, threads: threads}
+}
+
+// return the backend of the mode of the configuration, "s" (or "") is
+// sequential and "ws" and "wb" run on a new executor of the same name
+func newBackend(config Config) Backend {
+	if config.Mode == "s" || config.Mode == "" {
+		return sequentialEngine{}
+	} else if config.Mode != "ws" && config.Mode != "wb" {
+		panic("Invalid scheduling scheme: " + config.Mode)
+	}
+
+	if config.ThreadCount <= 0 {
+		config.ThreadCount = runtime.GOMAXPROCS(0)
+	}
+	e := &executorEngine{service: newExecutor(config), threads: config.ThreadCount, owned: true}
+	if config.Elastic {
+		e.scaler = concurrent.NewAutoscaler(e.service, 1, config.ThreadCount)
+	}
+	return e
+}
+
+// sequentialEngine runs everything on the calling goroutine
+type sequentialEngine struct{}
+
+func (sequentialEngine) Executor() concurrent.ExecutorService { return nil }
+
+func (sequentialEngine) Chunks() int { return 1 }
+
+func (sequentialEngine) InitBodies(bodies []*nbody.Body, numBodies int) {
+	for i := 0; i < numBodies; i++ {
+		nbody.InitPositionsAndVelocities(i, bodies, numBodies)
+	}
+}
+
+func (sequentialEngine) Accelerations(bodies []*nbody.Body, numBodies int, physics *nbody.Physics, ids []int) {
+	if ids == nil {
+		for i := 0; i < numBodies; i++ {
+			nbody.ComputeBodyAcceleration(i, bodies, numBodies, physics) // COMPUTE INTERBODY FORCES
+		}
+		return
+	}
+	for _, i := range ids {
+		nbody.ComputeBodyAcceleration(i, bodies, numBodies, physics) // COMPUTE INTERBODY FORCES
+	}
+}
+
+func (sequentialEngine) Forces(bodies []*nbody.Body, numBodies int, dt float32, physics *nbody.Physics) {
+	for i := 0; i < numBodies; i++ {
+		nbody.ComputeBodyForce(i, bodies, dt, numBodies, physics) // COMPUTE INTERBODY FORCES
+	}
+}
+
+func (sequentialEngine) Integrate(bodies []*nbody.Body, numBodies int, dt float32, box nbody.Box) {
+	for i := 0; i < numBodies; i++ {
+		nbody.IntegratePositions(i, bodies, numBodies, dt, box) // INTEGRATE POSITIONS
+	}
+}
+
+func (sequentialEngine) KickAndIntegrate(bodies []*nbody.Body, numBodies int, dt float32, box nbody.Box) {
+	for i := 0; i < numBodies; i++ {
+		nbody.KickBody(i, bodies, dt)
+		nbody.IntegratePositions(i, bodies, numBodies, dt, box) // INTEGRATE POSITIONS
+	}
+}
+
+func (sequentialEngine) BeginPhase(phase string) {}
+
+func (sequentialEngine) EndPhase(tasks int) {}
+
+func (sequentialEngine) Shutdown() {}
+
+// executorEngine submits one task per body to an executor and waits for
+// all of them after every phase
+type executorEngine struct {
+	service concurrent.ExecutorService
+	threads int
+	owned   bool // Whether shutdown stops the executor
+	futures []concurrent.Future
+	// Resizes an elastic executor between phases, nil if it is not resized
+	scaler *concurrent.Autoscaler
+}
+
+func (e *executorEngine) Executor() concurrent.ExecutorService { return e.service }
+
+func (e *executorEngine) Chunks() int { return 4 * e.threads }
+
+// submit one task per body of ids (all bodies if ids is nil) and wait for
+// all of them. The locality key of a task is the chunk of its body, so
+// with locality the bodies of a chunk stay on the worker that ran it last.
+func (e *executorEngine) run(phase string, ids []int, numBodies int, task func(i int) concurrent.Runnable) {
+	e.BeginPhase(phase)
+	e.futures = e.futures[:0]
+	size := (numBodies + e.Chunks() - 1) / e.Chunks()
+	if ids == nil {
+		for i := 0; i < numBodies; i++ {
+			e.futures = append(e.futures, concurrent.SubmitWith(e.service, task(i), 0, i/size))
+		}
+	} else {
+		for _, i := range ids {
+			e.futures = append(e.futures, concurrent.SubmitWith(e.service, task(i), 0, i/size))
+		}
+	}
+
+	for _, f := range e.futures {
+		f.Get()
+	}
+	e.EndPhase(len(e.futures))
+}
+
+func (e *executorEngine) BeginPhase(phase string) {
+	if e.scaler != nil {
+		e.scaler.Begin(phase)
+	}
+}
+
+func (e *executorEngine) EndPhase(tasks int) {
+	if e.scaler != nil {
+		e.scaler.End(tasks)
+	}
+}
+
+func (e *executorEngine) InitBodies(bodies []*nbody.Body, numBodies int) {
+	e.run("init", nil, numBodies, func(i int) concurrent.Runnable {
+		return NewNbodyTask(i, bodies, 0, numBodies, nil, InitPositionsAndVelocities)
+	})
+}
+
+func (e *executorEngine) Accelerations(bodies []*nbody.Body, numBodies int, physics *nbody.Physics, ids []int) {
+	e.run("accelerations", ids, numBodies, func(i int) concurrent.Runnable {
+		return NewNbodyTask(i, bodies, 0, numBodies, physics, ComputeAcceleration)
+	})
+}
+
+func (e *executorEngine) Forces(bodies []*nbody.Body, numBodies int, dt float32, physics *nbody.Physics) {
+	e.run("forces", nil, numBodies, func(i int) concurrent.Runnable {
+		return NewNbodyTask(i, bodies, dt, numBodies, physics, ComputeForce)
+	})
+}
+
+func (e *executorEngine) Integrate(bodies []*nbody.Body, numBodies int, dt float32, box nbody.Box) {
+	physics := &nbody.Physics{Box: box}
+	e.run("integrate", nil, numBodies, func(i int) concurrent.Runnable {
+		return NewNbodyTask(i, bodies, dt, numBodies, physics, IntegratePositions)
+	})
+}
+
+func (e *executorEngine) KickAndIntegrate(bodies []*nbody.Body, numBodies int, dt float32, box nbody.Box) {
+	physics := &nbody.Physics{Box: box}
+	e.run("kick", nil, numBodies, func(i int) concurrent.Runnable {
+		return NewNbodyTask(i, bodies, dt, numBodies, physics, KickAndIntegratePositions)
+	})
+}
+
+func (e *executorEngine) Shutdown() {
+	if e.owned {
+		e.service.Shutdown()
+	}
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"testing"
+
+	"proj3/nbody"
+)
+
+// run steps of the configuration from the bodies on a backend and return
+// the final bodies by id
+func finalBodies(config Config, backend Backend, bodies []nbody.BodyState, steps int) map[int]nbody.BodyState {
+	config.NBodies = 0
+	s := NewWithBackend(config, backend)
+	for _, body := range bodies {
+		s.AddBody(body)
+	}
+	s.Step(steps)
+	final := s.State().Bodies
+	s.Close()
+
+	byID := make(map[int]nbody.BodyState, len(final))
+	for _, body := range final {
+		byID[body.ID] = body
+	}
+	return byID
+}
+
+// TestBackendEquivalence runs the same bodies on every backend with every
+// solver, timestep, collision mode, boundary, external potential and force
+// law and fails unless the final bodies are bit for bit
+// those of the sequential run
+func TestBackendEquivalence(t *testing.T) {
+	const threads, steps = 4, 5
+	configs := []struct {
+		name   string
+		config Config
+	}{
+		{"direct", Config{}},
+		{"pm", Config{Solver: "pm"}},
+		{"fmm", Config{Solver: "fmm"}},
+		{"adaptive", Config{TimestepEta: 0.05}},
+		{"block", Config{BlockLevels: 4}},
+		{"graph", Config{TaskGraph: true, TaskHints: true, DiagnosticsEvery: 2}},
+		{"graph pm", Config{Solver: "pm", TaskGraph: true, DiagnosticsEvery: 2}},
+		{"merge", Config{Collisions: "merge", BodyRadius: 0.3}},
+		{"bounce", Config{Collisions: "bounce", BodyRadius: 0.3}},
+		{"periodic", Config{BoxSize: 2500}},
+		{"ewald", Config{BoxSize: 2500, Ewald: true}},
+		{"periodic pm", Config{Solver: "pm", BoxSize: 2500}},
+		{"external", Config{External: []nbody.ExternalSpec{
+			{Kind: "nfw", Mass: 1e5, A: 500},
+			{Kind: "uniform", Field: [3]float64{0, 0, -0.1}},
+		}}},
+		{"external pm", Config{Solver: "pm", External: []nbody.ExternalSpec{{Kind: "point", Mass: 1e4, A: 10}}}},
+		{"yukawa", Config{ForceLaw: "yukawa:lambda=2"}},
+		{"coulomb", Config{ForceLaw: "coulomb"}},
+		{"lennard-jones", Config{ForceLaw: "lennard-jones"}},
+		{"1pn", Config{ForceLaw: "1pn"}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			config := c.config
+			config.NBodies, config.ThreadCount, config.RecordPositions = 300, threads, "no"
+
+			// THE INITIAL BODIES ARE RANDOM, SO THEY ARE CREATED ONCE AND COPIED
+			initial := NewWithBackend(config, NewSequentialBackend())
+			bodies := initial.State().Bodies
+			initial.Close()
+			reference := finalBodies(config, NewSequentialBackend(), bodies, steps)
+
+			shared := config
+			shared.Mode = "ws"
+			executor := newExecutor(shared)
+			defer executor.Shutdown()
+			backends := []struct {
+				name    string
+				backend func() Backend
+			}{
+				{"ws", func() Backend { run := config; run.Mode = "ws"; return newBackend(run) }},
+				{"wb", func() Backend { run := config; run.Mode = "wb"; return newBackend(run) }},
+				{"executor", func() Backend { return NewExecutorBackend(executor, threads) }},
+			}
+
+			for _, b := range backends {
+				final := finalBodies(config, b.backend(), bodies, steps)
+				if len(final) != len(reference) {
+					t.Fatalf("%s: %d bodies, the sequential run has %d", b.name, len(final), len(reference))
+				}
+				for id, ref := range reference {
+					if body, ok := final[id]; !ok || body != ref {
+						t.Fatalf("%s: body %d is %+v, the sequential run has %+v", b.name, id, body, ref)
+					}
+				}
+			}
+		})
+	}
+}
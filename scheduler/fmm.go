@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"fmt"
+	"proj3/concurrent"
+	"proj3/nbody"
+)
+
+// fmmSolver computes accelerations with the fast multipole method
+type fmmSolver struct {
+	fmm     *nbody.FMM
+	physics *nbody.Physics
+	chunks  int
+}
+
+// return the fast multipole solver of the configuration
+func newFMMSolver(config Config, physics *nbody.Physics, chunks int) *fmmSolver {
+	order, theta := config.FMMOrder, config.FMMTheta
+	if order <= 0 {
+		order = 4
+	}
+	if theta <= 0 {
+		theta = 0.5
+	}
+	fmm, err := nbody.NewFMM(order, float64(theta), 16)
+	if err == nil && physics.Box.Periodic() {
+		err = fmt.Errorf("the FMM solver does not support periodic boxes")
+	}
+	if err != nil {
+		fmt.Println("INVALID FMM CONFIGURATION")
+		panic(err)
+	}
+	return &fmmSolver{fmm, physics, chunks}
+}
+
+// runnable task computing the multipole expansions of a range of cells of
+// one level, or finishing the downward pass of a range of branches
+type fmmTask struct {
+	fmm        *nbody.FMM
+	level      int // Level of the upward pass, -1 for the downward pass
+	start, end int
+}
+
+func (task *fmmTask) Run() {
+	if task.level >= 0 {
+		task.fmm.Upward(task.level, task.start, task.end)
+		return
+	}
+	for branch := task.start; branch < task.end; branch++ {
+		task.fmm.Descend(branch)
+	}
+}
+
+// the FMM computes all accelerations, ids only matters to the other solvers
+func (solver *fmmSolver) accelerations(executor concurrent.ExecutorService, bodies []*nbody.Body,
+	numBodies int, ids []int) {
+	if executor == nil {
+		if err := solver.fmm.Solve(bodies, numBodies, solver.physics); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if err := solver.fmm.Build(bodies, numBodies, solver.physics); err != nil {
+		panic(err)
+	}
+
+	// UPWARD PASS, ONE LEVEL AT A TIME FROM THE LEAVES
+	for level := solver.fmm.Levels() - 1; level >= 0; level-- {
+		runFMMPass(executor, solver.fmm, level, solver.fmm.LevelCells(level), solver.chunks)
+	}
+
+	// DOWNWARD PASS, THE TOP OF THE TREE IS SPLIT INTO INDEPENDENT BRANCHES
+	runFMMPass(executor, solver.fmm, -1, solver.fmm.Branches(solver.chunks), solver.chunks)
+}
+
+// split the n cells or branches of a pass into chunks tasks and wait for
+// all of them
+func runFMMPass(executor concurrent.ExecutorService, fmm *nbody.FMM, level, n, chunks int) {
+	futures := make([]concurrent.Future, 0, chunks)
+	for _, r := range chunkRanges(n, chunks) {
+		futures = append(futures, executor.Submit(&fmmTask{fmm, level, r[0], r[1]}))
+	}
+
+	for _, f := range futures {
+		f.Get()
+	}
+}
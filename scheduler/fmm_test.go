@@ -0,0 +1,26 @@
+package scheduler
+
+import "testing"
+
+// the error of the multipole solver against the direct sum falls as its
+// order rises, sequentially and split into tasks
+func TestFMMOrderError(t *testing.T) {
+	orders := []int{1, 2, 4, 6}
+	for _, mode := range []string{"s", "ws"} {
+		t.Run(mode, func(t *testing.T) {
+			results := CompareSolvers(Config{Mode: mode, NBodies: 600, ThreadCount: 4}, orders)
+			if len(results) != len(orders)+1 || results[0].Solver != "direct" {
+				t.Fatalf("results %+v", results)
+			}
+			for i := 2; i < len(results); i++ {
+				if results[i].MeanError >= results[i-1].MeanError {
+					t.Errorf("%s has mean error %.3e, %s has %.3e", results[i].Solver, results[i].MeanError,
+						results[i-1].Solver, results[i-1].MeanError)
+				}
+			}
+			if last := results[len(results)-1]; last.MeanError > 1e-3 {
+				t.Errorf("%s has mean error %.3e", last.Solver, last.MeanError)
+			}
+		})
+	}
+}
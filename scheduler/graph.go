@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"proj3/concurrent"
+	"proj3/nbody"
+)
+
+// runnable task running one phase on a chunk of bodies
+type chunkTask struct {
+	bodies     []*nbody.Body
+	numBodies  int
+	dt         float32
+	physics    *nbody.Physics
+	phase      Phase
+	start, end int
+}
+
+func (task *chunkTask) Run() {
+	for i := task.start; i < task.end; i++ {
+		task.phase(i, task.bodies, task.numBodies, task.dt, task.physics)
+	}
+}
+
+// runnable task giving a chunk of bodies the velocities and accelerations
+// their forces set on the frozen copies, then running a phase on them
+type thawTask struct {
+	chunkTask
+	frozen []*nbody.Body
+}
+
+func (task *thawTask) Run() {
+	for i := task.start; i < task.end; i++ {
+		// THE POSITION OF THE COPY IS STILL THAT OF THE BODY
+		*task.bodies[i] = *task.frozen[i]
+	}
+	task.chunkTask.Run()
+}
+
+// whether the step can run as one task graph: a fixed step of the direct
+// or particle mesh solver that no AfterForce hook needs to observe between
+// the phases. The adaptive timestep needs the largest acceleration of all
+// bodies before any of them moves and block timesteps choose the bodies of
+// every substep, the fmm builds its tree on the calling goroutine and only
+// knows its branches after the upward pass, so they keep the barriers.
+func (s *Simulation) graphStepEnabled() bool {
+	_, mesh := s.solver.(*meshSolver)
+	if !s.config.TaskGraph || s.blocks != nil || s.controller != nil || (s.solver != nil && !mesh) {
+		return false
+	}
+	for _, obs := range s.observers {
+		if obs.AfterForce != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// return copies of the bodies, reusing those of the last step
+func (s *Simulation) freeze() []*nbody.Body {
+	for len(s.frozen) < len(s.bodies) {
+		s.frozen = append(s.frozen, nbody.NewBody())
+	}
+	frozen := s.frozen[:len(s.bodies)]
+	for i, b := range s.bodies {
+		*frozen[i] = *b
+	}
+	return frozen
+}
+
+// run a fixed step as one task graph on the executor of the engine: the
+// forces of every chunk of bodies, the integration of every chunk as soon
+// as its own forces are done and, if the step is due for global
+// diagnostics, their partial reductions, each as soon as the chunks it
+// reads are done. It returns whether the diagnostics were recorded.
+func (s *Simulation) graphStep() bool {
+	bodies, numBodies, dt, physics := s.bodies, len(s.bodies), s.dt, s.physics
+	ranges := chunkRanges(numBodies, s.engine.Chunks())
+	g := concurrent.NewGraph()
+
+	// THE FORCES ARE THE CRITICAL PATH, A CHUNK PREFERS THE WORKER THAT RAN IT LAST STEP
+	integrated := make([]*concurrent.GraphNode, len(ranges))
+	if mesh, ok := s.solver.(*meshSolver); ok {
+		accelerated := mesh.graph(g, bodies, numBodies, ranges)
+		for k, r := range ranges {
+			integrated[k] = g.Add("integrate", &chunkTask{bodies, numBodies, dt, physics, KickAndIntegratePositions,
+				r[0], r[1]}, accelerated[k]).Hint(1, k)
+		}
+	} else {
+		// THE FORCES READ THE POSITIONS OF THE START OF THE STEP FROM COPIES, SO A CHUNK
+		// MOVES AS SOON AS ITS OWN FORCES ARE DONE WHILE THE OTHERS ARE STILL COMPUTED
+		frozen := s.freeze()
+		for k, r := range ranges {
+			force := g.Add("force", &chunkTask{frozen, numBodies, dt, physics, ComputeForce, r[0], r[1]}).Hint(1, k)
+			integrated[k] = g.Add("integrate", &thawTask{chunkTask{bodies, numBodies, dt, physics, IntegratePositions,
+				r[0], r[1]}, frozen}, force).Hint(1, k)
+		}
+	}
+
+	// COLLISIONS CHANGE THE BODIES AFTER THE GRAPH, THEIR DIAGNOSTICS WAIT FOR THEM
+	every := s.config.DiagnosticsEvery
+	due := every > 0 && (s.step+1)%every == 0 && !s.collisions
+	var accelerations []float32
+	var bounds []nbody.BoundingBox
+	var moments []nbody.Moments
+	var energies []energyParts
+	if due {
+		accelerations = make([]float32, len(ranges))
+		bounds = make([]nbody.BoundingBox, len(ranges))
+		moments = make([]nbody.Moments, len(ranges))
+		for k, r := range ranges {
+			k, start, end := k, r[0], r[1]
+			// A CHUNK IS REDUCED AS SOON AS IT IS DONE, WHILE OTHER CHUNKS ARE STILL COMPUTED
+			g.AddFunc("diagnostics", func() {
+				accelerations[k] = nbody.MaxAcceleration(bodies, start, end)
+				bounds[k] = nbody.Bounds(bodies, start, end)
+				moments[k] = nbody.BodyMoments(bodies, start, end)
+			}, integrated[k]).Hint(0, k)
+		}
+
+		// THE POTENTIAL ENERGY OF A CHUNK READS EVERY POSITION
+		positions := g.Join("integrated", integrated...)
+		energyRanges := chunkRanges(numBodies, 4*s.engine.Chunks())
+		energies = make([]energyParts, len(energyRanges))
+		for k, r := range energyRanges {
+			k, task := k, &energyTask{bodies, numBodies, physics, r[0], r[1]}
+			g.AddFunc("diagnostics", func() {
+				energies[k] = task.Call()
+			}, positions).Hint(1, concurrent.NoLocality)
+		}
+	}
+
+	s.engine.BeginPhase("graph")
+	stats := g.Run(s.engine.Executor())
+	s.engine.EndPhase(stats.Tasks)
+	s.stats.Graph = s.stats.Graph.Add(stats)
+	if !due {
+		return false
+	}
+
+	// COMBINE IN THE ORDER OF THE CHUNKS, AS Reduce DOES
+	d := GlobalDiagnostic{Step: s.step + 1, Time: s.time + dt}
+	energy, box, moment := energyParts{}, nbody.EmptyBoundingBox(), nbody.Moments{}
+	for _, e := range energies {
+		energy = addEnergyParts(energy, e)
+	}
+	for k := range ranges {
+		box, moment = box.Union(bounds[k]), moment.Add(moments[k])
+		if accelerations[k] > d.MaxAcceleration {
+			d.MaxAcceleration = accelerations[k]
+		}
+	}
+	d.Kinetic, d.Potential = energy.kinetic, energy.potential
+	d.CenterOfMass, d.Momentum = moment.CenterOfMass(), moment.Momentum
+	d.Bounds = box
+	s.stats.Globals = append(s.stats.Globals, d)
+	return true
+}
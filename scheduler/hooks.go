@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"proj3/nbody"
+	"proj3/snapshot"
+)
+
+// points of the simulation loop observers can hook into
+const (
+	hookBeforeStep = iota
+	hookAfterForce
+	hookAfterStep
+	hookSnapshot
+	hookFinish
+)
+
+// View is a read-only view of the bodies at one point of a simulation.
+// Synchronous observers see the bodies of the simulation and must not keep
+// the view after they return, asynchronous observers get a copy.
+type View struct {
+	Step   int
+	Time   float32
+	bodies []*nbody.Body
+}
+
+// Len returns the number of bodies
+func (v View) Len() int {
+	return len(v.bodies)
+}
+
+// Body returns a copy of the state of the i-th body
+func (v View) Body(i int) nbody.BodyState {
+	return v.bodies[i].State()
+}
+
+// WriteFrame writes the bodies as one frame with w, e.g. a csv or binary
+// snapshot writer
+func (v View) WriteFrame(w snapshot.BodyWriter) error {
+	return w.WriteFrame(v.Step, v.Time, v.bodies, len(v.bodies))
+}
+
+// return a view of copies of the bodies
+func (v View) copy() View {
+	storage := make([]nbody.Body, len(v.bodies))
+	bodies := make([]*nbody.Body, len(v.bodies))
+	for i, b := range v.bodies {
+		storage[i] = *b
+		bodies[i] = &storage[i]
+	}
+	return View{v.Step, v.Time, bodies}
+}
+
+// Observer is a set of hooks on the simulation loop, hooks left nil are
+// not called
+type Observer struct {
+	BeforeStep func(View) // Before every step
+	AfterForce func(View) // After every force computation, once per substep with block timesteps
+	AfterStep  func(View) // After every step, with collisions resolved
+	OnSnapshot func(View) // Every SnapshotEvery steps, starting with the initial state
+	OnFinish   func(View) // Once, with the final state, when the simulation is closed
+	// Run the hooks in order on a background goroutine of the observer
+	// instead of on the simulation goroutine, they receive copies of the
+	// bodies
+	Async bool
+}
+
+// return the hook of a kind, nil if it is not set
+func (o Observer) hook(kind int) func(View) {
+	switch kind {
+	case hookBeforeStep:
+		return o.BeforeStep
+	case hookAfterForce:
+		return o.AfterForce
+	case hookAfterStep:
+		return o.AfterStep
+	case hookSnapshot:
+		return o.OnSnapshot
+	}
+	return o.OnFinish
+}
+
+// hookEvent is a hook call queued for an asynchronous observer
+type hookEvent struct {
+	hook func(View)
+	view View
+}
+
+// registered observer, events is nil for synchronous ones
+type observer struct {
+	Observer
+	events chan hookEvent
+	done   chan struct{}
+}
+
+// return a registered observer, starting the goroutine of asynchronous
+// observers
+func newObserver(o Observer) *observer {
+	obs := &observer{Observer: o}
+	if o.Async {
+		obs.events = make(chan hookEvent, 16)
+		obs.done = make(chan struct{})
+		go func() {
+			defer close(obs.done)
+			for event := range obs.events {
+				event.hook(event.view)
+			}
+		}()
+	}
+	return obs
+}
+
+// call the hook of a kind, or queue it for asynchronous observers
+func (obs *observer) notify(kind int, view View) {
+	hook := obs.hook(kind)
+	if hook == nil {
+		return
+	}
+	if obs.events == nil {
+		hook(view)
+		return
+	}
+	obs.events <- hookEvent{hook, view.copy()}
+}
+
+// wait for the queued hooks of asynchronous observers
+func (obs *observer) close() {
+	if obs.events != nil {
+		close(obs.events)
+		<-obs.done
+	}
+}
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"proj3/nbody"
+)
+
+// return an observer appending "<name> <hook> <step>" to log for every hook
+func loggingObserver(name string, log *[]string, lock *sync.Mutex, async bool) Observer {
+	hook := func(kind string) func(View) {
+		return func(v View) {
+			lock.Lock()
+			*log = append(*log, fmt.Sprintf("%s %s %d", name, kind, v.Step))
+			lock.Unlock()
+		}
+	}
+	return Observer{
+		BeforeStep: hook("before"),
+		AfterForce: hook("force"),
+		AfterStep:  hook("after"),
+		OnSnapshot: hook("snapshot"),
+		OnFinish:   hook("finish"),
+		Async:      async,
+	}
+}
+
+// the hooks run in the order of the loop, observers in the order they were
+// registered
+func TestHookOrder(t *testing.T) {
+	var log []string
+	var lock sync.Mutex
+	s := New(Config{NBodies: 4, SnapshotEvery: 2, RecordPositions: "no"})
+	s.Observe(loggingObserver("a", &log, &lock, false))
+	s.Observe(loggingObserver("b", &log, &lock, false))
+	s.Step(3)
+	s.Close()
+
+	var want []string
+	for _, event := range []string{
+		"snapshot 0", "before 0", "force 0", "after 1",
+		"before 1", "force 1", "after 2",
+		"snapshot 2", "before 2", "force 2", "after 3",
+		"finish 3",
+	} {
+		want = append(want, "a "+event, "b "+event)
+	}
+	if strings.Join(log, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("hooks ran as\n%s\nwant\n%s", strings.Join(log, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// an asynchronous observer running behind the simulation sees the bodies
+// of the step of every event, and its events all run before OnFinish and
+// before Close returns
+func TestAsyncObserver(t *testing.T) {
+	const steps = 5
+	s := New(Config{RecordPositions: "no"})
+	// THE FIRST BODY MOVES ALONG X EVERY STEP
+	s.AddBody(nbody.BodyState{VX: 1, Mass: 1})
+	s.AddBody(nbody.BodyState{X: 10, Y: 10, Mass: 1})
+
+	var positions []float32
+	s.Observe(Observer{AfterStep: func(v View) {
+		positions = append(positions, v.Body(0).X)
+	}})
+
+	gate := make(chan struct{})
+	var async []float32
+	var log []string
+	var lock sync.Mutex
+	s.Observe(Observer{
+		AfterStep: func(v View) {
+			<-gate
+			async = append(async, v.Body(0).X)
+		},
+		Async: true,
+	})
+	s.Observe(loggingObserver("async", &log, &lock, true))
+
+	// THE SIMULATION RUNS AHEAD WHILE THE OBSERVER IS HELD
+	s.Step(steps)
+	close(gate)
+	s.Close()
+
+	if positions[0] == positions[steps-1] {
+		t.Fatal("the body did not move, the copies cannot be told apart")
+	}
+	if len(async) != steps {
+		t.Fatalf("%d asynchronous events, want %d", len(async), steps)
+	}
+	for i := range positions {
+		if async[i] != positions[i] {
+			t.Fatalf("step %d: asynchronous view has x %g, the step had %g", i+1, async[i], positions[i])
+		}
+	}
+	if last := log[len(log)-1]; last != fmt.Sprintf("async finish %d", steps) {
+		t.Fatalf("last asynchronous event %q", last)
+	}
+}
@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"proj3/concurrent"
+	"proj3/nbody"
+	"proj3/render"
+	"proj3/snapshot"
+)
+
+const (
+	liveColumns = 80 // Width of the live plot in characters
+	liveRows    = 24 // Height of the live plot in characters
+
+	// Initial energies closer to zero are too small to divide the energy
+	// error by, the absolute error is shown instead
+	minRelativeEnergy = 1e-9
+)
+
+// liveView redraws a braille plot of the bodies and the progress of the run
+// in the terminal every few steps
+type liveView struct {
+	out        io.Writer
+	every      int
+	iterations int
+	opts       render.Options
+	frame      *snapshot.Frame
+	executor   *concurrent.ExecService // nil for the sequential version
+	tasks      concurrent.ExecutorService
+	chunks     int
+	physics    *nbody.Physics
+	energy0    float64
+	redraws    int
+	lastStep   int
+	lastTime   time.Time
+	lastBusy   time.Duration
+}
+
+// return a live view for the configuration that measures the energy with
+// the physics of the simulation, or nil if it is disabled
+func newLiveView(config Config, physics *nbody.Physics, executor concurrent.ExecutorService, chunks int) *liveView {
+	if config.LiveEvery <= 0 {
+		return nil
+	}
+
+	opts := render.DefaultOptions()
+	if config.LiveProjection != "" {
+		opts.View = config.LiveProjection
+	}
+
+	lv := &liveView{
+		out:        os.Stdout,
+		every:      config.LiveEvery,
+		iterations: config.Iterations,
+		opts:       opts,
+		frame:      snapshot.NewFrame([]string{"x", "y", "z"}, 0),
+		tasks:      executor,
+		chunks:     chunks,
+		physics:    physics,
+		lastTime:   time.Now(),
+	}
+	if e, ok := executor.(*concurrent.ExecService); ok {
+		e.TrackUtilization()
+		lv.executor = e
+		lv.lastBusy = e.BusyTime()
+	}
+	return lv
+}
+
+// record the initial energy the energy error is measured against
+func (lv *liveView) start(bodies []*nbody.Body, numBodies int) {
+	lv.energy0 = lv.energy(bodies, numBodies)
+}
+
+// return the total energy, on the executor if there is one
+func (lv *liveView) energy(bodies []*nbody.Body, numBodies int) float64 {
+	kinetic, potential := parallelEnergy(lv.tasks, bodies, numBodies, lv.physics, lv.chunks)
+	return kinetic + potential
+}
+
+// redraw the view if step is a multiple of the refresh interval
+func (lv *liveView) update(step int, bodies []*nbody.Body, numBodies int) {
+	if step%lv.every != 0 {
+		return
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(lv.lastTime).Seconds()
+	stepsPerSec := float64(step-lv.lastStep) / elapsed
+
+	label, energyError := energyError(lv.energy(bodies, numBodies), lv.energy0)
+
+	lv.frame.Fill(step, 0, bodies, numBodies)
+	renderer, err := render.New(lv.opts, []*snapshot.Frame{lv.frame})
+	if err != nil {
+		fmt.Println("INVALID LIVE VIEW CONFIGURATION")
+		panic(err)
+	}
+
+	status := fmt.Sprintf("STEP %d/%d  STEPS/S %.2f  %s %.3e", step, lv.iterations, stepsPerSec, label, energyError)
+	if lv.executor != nil {
+		busy := lv.executor.BusyTime()
+		utilization := (busy - lv.lastBusy).Seconds() / (elapsed * float64(lv.executor.Capacity()))
+		status += fmt.Sprintf("  UTILIZATION %.1f%%", 100*utilization)
+	}
+
+	// MOVE THE CURSOR HOME AND CLEAR THE SCREEN BEFORE DRAWING
+	fmt.Fprint(lv.out, "\x1b[H\x1b[2J"+renderer.Braille(lv.frame, lv.redraws, liveColumns, liveRows)+status+"\n")
+
+	lv.redraws++
+	lv.lastStep = step
+	// THE ENERGY AND THE PLOT ARE NOT PART OF THE NEXT INTERVAL
+	lv.lastTime = time.Now()
+	if lv.executor != nil {
+		lv.lastBusy = lv.executor.BusyTime()
+	}
+}
+
+// return the label and value of the error of energy against energy0,
+// relative unless energy0 is too close to zero
+func energyError(energy, energy0 float64) (string, float64) {
+	if math.Abs(energy0) < minRelativeEnergy {
+		return "ABSOLUTE ENERGY ERROR", math.Abs(energy - energy0)
+	}
+	return "ENERGY ERROR", math.Abs((energy - energy0) / energy0)
+}
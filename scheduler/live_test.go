@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"proj3/nbody"
+)
+
+func TestEnergyError(t *testing.T) {
+	tests := []struct {
+		energy, energy0 float64
+		label           string
+		want            float64
+	}{
+		{-0.99, -1, "ENERGY ERROR", 0.01},
+		{2.5, 2, "ENERGY ERROR", 0.25},
+		{1e-3, 0, "ABSOLUTE ENERGY ERROR", 1e-3},
+		{0, 1e-12, "ABSOLUTE ENERGY ERROR", 1e-12},
+	}
+	for _, test := range tests {
+		label, got := energyError(test.energy, test.energy0)
+		if label != test.label || math.Abs(got-test.want) > 1e-12*math.Max(1, test.want) {
+			t.Errorf("energyError(%g, %g) = %s %g, want %s %g",
+				test.energy, test.energy0, label, got, test.label, test.want)
+		}
+	}
+}
+
+// a run starting at zero energy shows a finite absolute error, measured
+// with the physics of the simulation
+func TestLiveViewZeroEnergy(t *testing.T) {
+	s := New(Config{Iterations: 1, LiveEvery: 1, RecordPositions: "no"})
+	defer s.Close()
+	if s.live.physics != s.physics {
+		t.Fatal("the live view has its own physics")
+	}
+	var out bytes.Buffer
+	s.live.out = &out
+	s.AddBody(nbody.BodyState{Mass: 1})
+	s.Step(1)
+
+	if !strings.Contains(out.String(), "ABSOLUTE ENERGY ERROR 0.000e+00") {
+		t.Fatalf("status line of a body at rest: %q", out.String()[strings.LastIndex(out.String(), "STEP"):])
+	}
+}
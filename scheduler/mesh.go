@@ -0,0 +1,214 @@
+package scheduler
+
+import (
+	"fmt"
+	"proj3/concurrent"
+	"proj3/nbody"
+)
+
+// passes of the particle mesh solve that are split into tasks
+const (
+	meshAssign = iota
+	meshGather
+	meshTransform
+	meshInverseTransform
+	meshConvolve
+	meshDifferentiate
+	meshInterpolate
+)
+
+// meshSolver computes accelerations with a particle mesh
+type meshSolver struct {
+	mesh     *nbody.ParticleMesh
+	external nbody.ExternalPotential
+	chunks   int
+}
+
+// return the particle mesh solver of the configuration
+func newMeshSolver(config Config, physics *nbody.Physics, chunks int) *meshSolver {
+	cells := config.MeshCells
+	if cells <= 0 {
+		cells = 32
+	}
+	mesh, err := nbody.NewParticleMesh(cells, physics.Box)
+	if err != nil {
+		fmt.Println("INVALID PARTICLE MESH CONFIGURATION")
+		panic(err)
+	}
+	return &meshSolver{mesh, physics.External, chunks}
+}
+
+func (solver *meshSolver) accelerations(executor concurrent.ExecutorService, bodies []*nbody.Body,
+	numBodies int, ids []int) {
+	if executor != nil {
+		parallelMeshAccelerations(executor, solver.mesh, solver.external, bodies, numBodies, ids, solver.chunks)
+		return
+	}
+
+	solver.mesh.Solve(bodies, numBodies)
+	task := meshTask{mesh: solver.mesh, pass: meshInterpolate, bodies: bodies, external: solver.external,
+		ids: ids, end: len(ids)}
+	if ids == nil {
+		task.end = numBodies
+	}
+	task.Run()
+}
+
+// runnable task running one pass of the particle mesh over a range
+type meshTask struct {
+	mesh       *nbody.ParticleMesh
+	pass       int
+	part, axis int
+	bodies     []*nbody.Body
+	external   nbody.ExternalPotential // Added to the interpolated accelerations, nil for none
+	ids        []int                   // Bodies to interpolate, nil for all
+	start, end int
+}
+
+func (task *meshTask) Run() {
+	switch task.pass {
+	case meshAssign:
+		task.mesh.Assign(task.part, task.bodies, task.start, task.end)
+	case meshGather:
+		task.mesh.Gather(task.start, task.end)
+	case meshTransform:
+		task.mesh.Transform(task.axis, task.start, task.end, false)
+	case meshInverseTransform:
+		task.mesh.Transform(task.axis, task.start, task.end, true)
+	case meshConvolve:
+		task.mesh.Convolve(task.start, task.end)
+	case meshDifferentiate:
+		task.mesh.Differentiate(task.start, task.end)
+	case meshInterpolate:
+		for k := task.start; k < task.end; k++ {
+			i := k
+			if task.ids != nil {
+				i = task.ids[k]
+			}
+			task.mesh.Interpolate(i, task.bodies)
+			if task.external != nil {
+				nbody.AddExternalAcceleration(i, task.bodies, task.external)
+			}
+		}
+	}
+}
+
+// split [0, n) into chunks tasks of one pass and wait for all of them
+func runMeshPass(executor concurrent.ExecutorService, template meshTask, n, chunks int) {
+	if n == 0 || chunks == 0 {
+		return
+	}
+	futures := make([]concurrent.Future, 0, chunks)
+	for _, task := range meshPassTasks(template, n, chunks) {
+		futures = append(futures, executor.Submit(task))
+	}
+
+	for _, f := range futures {
+		f.Get()
+	}
+}
+
+// return the tasks of one pass over [0, n) split into chunks ranges, the
+// k-th task of the assignment adds to the grid of part k
+func meshPassTasks(template meshTask, n, chunks int) []*meshTask {
+	var tasks []*meshTask
+	for part, r := range chunkRanges(n, chunks) {
+		task := template
+		task.part, task.start, task.end = part, r[0], r[1]
+		tasks = append(tasks, &task)
+	}
+	return tasks
+}
+
+// compute the accelerations of the bodies ids (all bodies if ids is nil)
+// with the particle mesh, every pass is split into tasks on the executor
+func parallelMeshAccelerations(executor concurrent.ExecutorService, mesh *nbody.ParticleMesh,
+	external nbody.ExternalPotential, bodies []*nbody.Body, numBodies int, ids []int, chunks int) {
+	// WITHOUT BODIES (ALL OF THEM MAY HAVE BEEN REMOVED) THERE IS NOTHING TO ASSIGN OR INTERPOLATE
+	if numBodies == 0 {
+		return
+	}
+	// EVERY PART ASSIGNS TO ITS OWN GRID, THEY ARE SUMMED BY THE GATHER PASS
+	parts := chunks
+	if parts > numBodies {
+		parts = numBodies
+	}
+	mesh.FitBounds(parallelBounds(executor, bodies, numBodies, chunks), parts)
+	runMeshPass(executor, meshTask{mesh: mesh, pass: meshAssign, bodies: bodies}, numBodies, parts)
+	runMeshPass(executor, meshTask{mesh: mesh, pass: meshGather}, mesh.Points(), chunks)
+	for axis := 0; axis < 3; axis++ {
+		runMeshPass(executor, meshTask{mesh: mesh, pass: meshTransform, axis: axis}, mesh.Lines(), chunks)
+	}
+	runMeshPass(executor, meshTask{mesh: mesh, pass: meshConvolve}, mesh.Points(), chunks)
+	for axis := 0; axis < 3; axis++ {
+		runMeshPass(executor, meshTask{mesh: mesh, pass: meshInverseTransform, axis: axis}, mesh.Lines(), chunks)
+	}
+	runMeshPass(executor, meshTask{mesh: mesh, pass: meshDifferentiate}, mesh.FieldPoints(), chunks)
+
+	n := numBodies
+	if ids != nil {
+		n = len(ids)
+	}
+	runMeshPass(executor, meshTask{mesh: mesh, pass: meshInterpolate, bodies: bodies, external: external, ids: ids}, n, chunks)
+}
+
+// add the passes of the particle mesh to a task graph, every pass once the
+// previous one is done, and return the interpolation of the accelerations
+// of every range of bodies. The passes are those of
+// parallelMeshAccelerations, so the accelerations are the same.
+func (solver *meshSolver) graph(g *concurrent.Graph, bodies []*nbody.Body, numBodies int,
+	ranges [][2]int) []*concurrent.GraphNode {
+	interpolated := make([]*concurrent.GraphNode, len(ranges))
+	if numBodies == 0 {
+		return interpolated
+	}
+	mesh, chunks := solver.mesh, solver.chunks
+	parts := chunks
+	if parts > numBodies {
+		parts = numBodies
+	}
+
+	// EVERY PASS IS ON THE CRITICAL PATH, THE GRID IS FITTED ONCE THE BOUNDS OF EVERY CHUNK ARE KNOWN
+	boundRanges := chunkRanges(numBodies, chunks)
+	bounds := make([]nbody.BoundingBox, len(boundRanges))
+	boxes := make([]*concurrent.GraphNode, len(boundRanges))
+	for k, r := range boundRanges {
+		k, start, end := k, r[0], r[1]
+		boxes[k] = g.AddFunc("force", func() {
+			bounds[k] = nbody.Bounds(bodies, start, end)
+		}).Hint(1, concurrent.NoLocality)
+	}
+	previous := g.AddFunc("force", func() {
+		box := nbody.EmptyBoundingBox()
+		for _, b := range bounds {
+			box = box.Union(b)
+		}
+		mesh.FitBounds(box, parts)
+	}, boxes...).Hint(1, concurrent.NoLocality)
+
+	pass := func(template meshTask, n, chunks int) {
+		tasks := meshPassTasks(template, n, chunks)
+		nodes := make([]*concurrent.GraphNode, len(tasks))
+		for k, task := range tasks {
+			nodes[k] = g.Add("force", task, previous).Hint(1, concurrent.NoLocality)
+		}
+		previous = g.Join("pm", nodes...)
+	}
+	pass(meshTask{mesh: mesh, pass: meshAssign, bodies: bodies}, numBodies, parts)
+	pass(meshTask{mesh: mesh, pass: meshGather}, mesh.Points(), chunks)
+	for axis := 0; axis < 3; axis++ {
+		pass(meshTask{mesh: mesh, pass: meshTransform, axis: axis}, mesh.Lines(), chunks)
+	}
+	pass(meshTask{mesh: mesh, pass: meshConvolve}, mesh.Points(), chunks)
+	for axis := 0; axis < 3; axis++ {
+		pass(meshTask{mesh: mesh, pass: meshInverseTransform, axis: axis}, mesh.Lines(), chunks)
+	}
+	pass(meshTask{mesh: mesh, pass: meshDifferentiate}, mesh.FieldPoints(), chunks)
+
+	// A BODY ONLY READS THE FIELD AND ITS OWN POSITION, SO ITS CHUNK CAN BE INTEGRATED RIGHT AFTER
+	for k, r := range ranges {
+		interpolated[k] = g.Add("force", &meshTask{mesh: mesh, pass: meshInterpolate, bodies: bodies,
+			external: solver.external, start: r[0], end: r[1]}, previous).Hint(1, k)
+	}
+	return interpolated
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"testing"
+
+	"proj3/concurrent"
+	"proj3/nbody"
+)
+
+// the passes split into more chunks than there are bodies, or over no
+// bodies at all, give the accelerations of the sequential solve
+func TestParallelMeshFewBodies(t *testing.T) {
+	const chunks = 8
+	executor := concurrent.NewWorkStealingExecutor(4, 1)
+	defer executor.Shutdown()
+
+	for _, n := range []int{0, 1, 3} {
+		states := []nbody.BodyState{
+			{X: 1, Y: -2, Z: 0.5, Mass: 1},
+			{X: -3, Y: 1, Z: 2, Mass: 2},
+			{X: 0.5, Y: 3, Z: -1, Mass: 1},
+		}[:n]
+		sequential, parallel := make([]*nbody.Body, n), make([]*nbody.Body, n)
+		for i, state := range states {
+			sequential[i], parallel[i] = nbody.NewBodyFromState(state), nbody.NewBodyFromState(state)
+		}
+
+		mesh, err := nbody.NewParticleMesh(16, nbody.Box{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mesh.Solve(sequential, n)
+		for i := range sequential {
+			mesh.Interpolate(i, sequential)
+		}
+		parallelMesh, _ := nbody.NewParticleMesh(16, nbody.Box{})
+		parallelMeshAccelerations(executor, parallelMesh, nil, parallel, n, nil, chunks)
+
+		for i := range parallel {
+			if got, want := parallel[i].State(), sequential[i].State(); got != want {
+				t.Errorf("%d bodies: body %d is %+v, the sequential solve gives %+v", n, i, got, want)
+			}
+		}
+	}
+}
@@ -8,18 +8,18 @@ plt.rcParams["figure.autolayout"] = True
 
 fig = plt.figure(figsize=(10, 10))
 
-data = pd.read_csv("nbody.csv", header=None, names=["iter", "x", "y", "z"])
+data = pd.read_csv("nbody.csv", skipinitialspace=True)
 
-all_iterations = data.iter.unique()
+all_iterations = data.step.unique()
 
 data_dict = {elem: pd.DataFrame() for elem in all_iterations}
 
 for key in data_dict.keys():
-    data_dict[key] = data[:][data.iter == key]
+    data_dict[key] = data[:][data.step == key]
 
 
 def animate(i):
-    data_val = data_dict[i + 1]
+    data_val = data_dict[all_iterations[i + 1]]
     plt.clf()
 
     ax = plt.axes(projection="3d")
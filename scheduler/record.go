@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"proj3/nbody"
+	"proj3/snapshot"
+)
+
+// recorder writes snapshots to a file on a background goroutine
+type recorder struct {
+	file   *os.File
+	buffer *bufio.Writer
+	*snapshot.AsyncWriter
+}
+
+// return the directory of the snapshot file of the configuration
+func recordDir(config Config) string {
+	if config.RecordDir != "" {
+		return config.RecordDir
+	} else if config.Mode == "s" || config.Mode == "" {
+		return "../scheduler/sequential"
+	}
+	return "../scheduler/parallel"
+}
+
+// open the snapshot file in dir and return a recorder for the configured
+// format and columns
+func newRecorder(config Config, dir string) *recorder {
+	name := "nbody.csv"
+	if config.RecordFormat == "bin" {
+		name = "nbody.nbs"
+	}
+
+	file, err := os.Create(dir + "/" + name)
+	if err != nil {
+		fmt.Println("ERROR WHEN OPENING FILE \"" + name + "\"")
+		panic(err)
+	}
+	buffer := bufio.NewWriterSize(file, 1<<20)
+	columns := config.CSVColumns
+	if columns == "" {
+		columns = "positions"
+	}
+
+	var writer snapshot.BodyWriter
+	if config.RecordFormat == "bin" {
+		var fields []string
+		fields, err = nbody.ParseColumns(columns)
+		if err == nil {
+			writer, err = snapshot.NewWriter(buffer, snapshot.BodyFields(fields))
+		}
+	} else if config.RecordFormat == "csv" || config.RecordFormat == "" {
+		writer, err = nbody.NewCSVWriter(buffer, columns, config.CSVPrecision)
+	} else {
+		err = fmt.Errorf("unknown record format %q", config.RecordFormat)
+	}
+	if err != nil {
+		file.Close()
+		fmt.Println("INVALID RECORD CONFIGURATION")
+		panic(err)
+	}
+	return &recorder{file: file, buffer: buffer, AsyncWriter: snapshot.NewAsyncWriter(writer)}
+}
+
+// wait for the pending snapshots, flush and close the file
+func (r *recorder) Close() snapshot.Stats {
+	err := r.AsyncWriter.Close()
+	if err == nil {
+		err = r.buffer.Flush()
+	}
+	if err == nil {
+		err = r.file.Close()
+	}
+	if err != nil {
+		fmt.Println("ERROR WHEN WRITING SNAPSHOT")
+		panic(err)
+	}
+	return r.Stats()
+}
+
+// return the observer writing a frame at every snapshot and closing the
+// file when the simulation finishes
+func (r *recorder) observer() Observer {
+	return Observer{
+		OnSnapshot: func(v View) {
+			if err := v.WriteFrame(r); err != nil {
+				fmt.Println("ERROR WHEN WRITING SNAPSHOT")
+				panic(err)
+			}
+		},
+		OnFinish: func(View) {
+			r.Close()
+		},
+	}
+}
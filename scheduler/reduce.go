@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"proj3/concurrent"
+)
+
+// Reduce splits [0, n) into at most chunks ranges, computes the partial
+// result of every range with the callable task returned by partial and
+// combines the results in the order of the ranges, starting from identity.
+// Every range is one task on the executor, with a nil executor the tasks
+// run on the calling goroutine. With n == 0 the result is identity.
+func Reduce[T any](executor concurrent.ExecutorService, n, chunks int, partial func(start, end int) concurrent.TypedCallable[T],
+	identity T, combine func(a, b T) T) T {
+	var tasks []concurrent.TypedCallable[T]
+	for _, r := range chunkRanges(n, chunks) {
+		tasks = append(tasks, partial(r[0], r[1]))
+	}
+
+	result := identity
+	if executor == nil {
+		for _, task := range tasks {
+			result = combine(result, task.Call())
+		}
+		return result
+	}
+
+	futures := make([]*concurrent.TypedFuture[T], len(tasks))
+	for k, task := range tasks {
+		futures[k] = concurrent.SubmitCallable(executor, task)
+	}
+	for _, f := range futures {
+		result = combine(result, f.Get())
+	}
+	return result
+}
+
+// split [0, n) into at most chunks ranges [start, end) of equal size
+func chunkRanges(n, chunks int) [][2]int {
+	if chunks < 1 {
+		chunks = 1
+	}
+	var ranges [][2]int
+	size := (n + chunks - 1) / chunks
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"proj3/concurrent"
+)
+
+func TestChunkRanges(t *testing.T) {
+	tests := []struct {
+		n, chunks int
+		want      [][2]int
+	}{
+		{10, 3, [][2]int{{0, 4}, {4, 8}, {8, 10}}},
+		{8, 4, [][2]int{{0, 2}, {2, 4}, {4, 6}, {6, 8}}},
+		{2, 8, [][2]int{{0, 1}, {1, 2}}},
+		{5, 0, [][2]int{{0, 5}}},
+		{0, 4, nil},
+	}
+	for _, test := range tests {
+		if ranges := chunkRanges(test.n, test.chunks); !reflect.DeepEqual(ranges, test.want) {
+			t.Errorf("chunkRanges(%d, %d) = %v, want %v", test.n, test.chunks, ranges, test.want)
+		}
+	}
+}
+
+// callable task naming its range, the first ranges finish last
+type rangeTask struct {
+	start, end int
+}
+
+func (task rangeTask) Call() string {
+	time.Sleep(time.Duration(10-task.start) * time.Millisecond)
+	return fmt.Sprintf("[%d,%d)", task.start, task.end)
+}
+
+// the partial results are combined in the order of the ranges, whichever
+// task finishes first
+func TestReduceOrder(t *testing.T) {
+	executor := concurrent.NewWorkStealingExecutor(4, 1)
+	defer executor.Shutdown()
+	partial := func(start, end int) concurrent.TypedCallable[string] {
+		return rangeTask{start, end}
+	}
+	concat := func(a, b string) string { return a + b }
+
+	tests := []struct {
+		n, chunks int
+		want      string
+	}{
+		{10, 3, "id[0,4)[4,8)[8,10)"},
+		{3, 8, "id[0,1)[1,2)[2,3)"},
+		{0, 4, "id"},
+	}
+	for _, test := range tests {
+		for name, e := range map[string]concurrent.ExecutorService{"executor": executor, "nil": nil} {
+			if result := Reduce(e, test.n, test.chunks, partial, "id", concat); result != test.want {
+				t.Errorf("%s: Reduce of %d in %d chunks = %q, want %q", name, test.n, test.chunks, result, test.want)
+			}
+		}
+	}
+}
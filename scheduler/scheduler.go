@@ -1,5 +1,12 @@
 package scheduler
 
+import (
+	"fmt"
+	"proj3/concurrent"
+	"proj3/nbody"
+	"proj3/snapshot"
+)
+
 type Config struct {
 	Mode string // Represents which scheduler scheme to use
 	// If Mode == "s" run the sequential version
@@ -11,27 +18,159 @@ type Config struct {
 	RecordPositions string // Record positions of the Bodies in a csv file
 	// If RecordPositions = "yes" record positions
 	// Or else don't record positions
-	ThreadCount int // Number of go routines for the parallel versions
+	ThreadCount  int    // Number of go routines for the parallel versions
+	RecordFormat string // Format of the recorded positions
+	// If RecordFormat == "csv" (or "") write nbody.csv
+	// If RecordFormat == "bin" write the binary snapshot file nbody.nbs
+	RecordDir string // Directory of the snapshot file
+	// Defaults to ../scheduler/sequential in mode "s" and to
+	// ../scheduler/parallel otherwise
+	CSVColumns string // Columns written to the snapshot file, defaults to "positions"
+	// Either a column set ("positions", "state", "full")
+	// or a comma separated list of column names
+	CSVPrecision int // Digits after the decimal point for floats in the csv file
+	LiveEvery    int // Redraw the live terminal view every LiveEvery steps
+	// If LiveEvery <= 0 the live view is disabled and costs nothing
+	LiveProjection string  // View of the live plot: "xy", "xz", "yz" or "3d"
+	TimestepEta    float32 // Accuracy parameter of the adaptive timestep
+	// dt = TimestepEta * sqrt(softening length / |a|max) every step, the
+	// length is SofteningLength (0.01 by default) without softening
+	// If TimestepEta <= 0 every step uses the fixed dt of 0.01
+	TimestepMin     float32 // Smallest adaptive timestep, defaults to TimestepMax / 1000
+	TimestepMax     float32 // Largest adaptive timestep, defaults to the fixed dt
+	SofteningKernel string  // Softening of the force: "plummer" (default), "spline" or "none"
+	SofteningLength float32 // Softening length, defaults to 0.01
+	ForceLaw        string  // Pairwise force law replacing gravity, e.g. "yukawa:lambda=50"
+	// One of nbody.ForceLaws() with its parameters, "" or "gravity" is
+	// Newtonian gravity. Other laws need the direct sum solver.
+	BoxSize float32 // Side of the periodic box centered on the origin
+	// If BoxSize <= 0 space is open, otherwise positions are wrapped into
+	// the box and forces use the nearest image of every body
+	Ewald  bool   // Add the Ewald correction for all other periodic images
+	Solver string // Force solver: "direct" (default) sum over all pairs, "pm" or "fmm"
+	// The particle mesh solver ignores the softening kernel and the Ewald
+	// correction, the grid spacing softens the force and the FFT makes it
+	// periodic in a box. The fast multipole solver only supports open space.
+	MeshCells int                  // Grid points per side of the particle mesh, a power of two (default 32)
+	FMMOrder  int                  // Order of the multipole expansions (default 4)
+	FMMTheta  float32              // Opening angle of the multipole solver (default 0.5)
+	External  []nbody.ExternalSpec // Analytic potentials acting on every body
+	// They are added to the acceleration of every body by every solver and
+	// to the potential energy, e.g. the host galaxy of a star cluster
+	Collisions string // Collision handling: "none" (default), "merge" or "bounce"
+	// Bodies closer than the sum of their radii are merged, conserving mass
+	// and momentum, or bounced elastically. With block timesteps collisions
+	// are handled once per iteration.
+	BodyRadius  float32 // Initial collision radius of every body
+	BlockLevels int     // Number of power-of-two block timestep levels
+	// If BlockLevels > 0 every body steps TimestepMax / 2^level with its
+	// level chosen from its own acceleration (TimestepEta defaults to 0.05)
+	// and an iteration is one step of TimestepMax
+	SnapshotEvery int // Steps between recorded snapshots, defaults to Iterations / 10
+	// Compute the global diagnostics (energies, center of mass, momentum,
+	// bounding box and largest acceleration) every DiagnosticsEvery steps,
+	// with parallel reductions in the parallel versions. 0 disables them.
+	DiagnosticsEvery int
+	// Run every fixed step of the direct or pm solver as one task graph,
+	// where a chunk of bodies is integrated as soon as its own forces are
+	// done and reduced for the diagnostics as soon as it is integrated,
+	// instead of at barriers. Adaptive and block timesteps, the fmm and
+	// steps with AfterForce observers keep the barriers.
+	TaskGraph bool
+	// Queue the tasks of the parallel versions by priority, critical path
+	// first, and send the tasks of a chunk of bodies to the worker that ran
+	// the chunk last
+	TaskHints bool
+	// Resize the executor of the parallel versions before every phase,
+	// between 1 and ThreadCount workers (at most GOMAXPROCS), to the
+	// number with the best throughput
+	Elastic bool
+	// Most tasks queued and not started in the executor of the parallel
+	// versions, the phases block while there are as many. 0 is unbounded.
+	QueueCapacity int
+	// Called on the simulation goroutine with the state after every step
+	// (and once before the first), e.g. to stream it to viewers
+	OnStep func(step int, time float32, bodies []*nbody.Body, numBodies int) `json:"-"`
+}
+
+// Stats reports what happened during a run
+type Stats struct {
+	Snapshots snapshot.Stats // Time spent on snapshots, zero if positions were not recorded
+	Timesteps []Timestep     // Timestep history, empty unless the timestep is adaptive
+	// With block timesteps, the number of substeps, of force computations
+	// and of bodies on every level at the end of the run
+	Substeps      int
+	ActiveUpdates int64
+	Levels        []int
+	Collisions    []CollisionEvent // Collisions in the order they were resolved
+	Survivors     int              // Number of bodies left at the end of the run
+	// Global diagnostics every DiagnosticsEvery steps, starting with the
+	// initial bodies
+	Globals []GlobalDiagnostic
+	Graph   concurrent.GraphStats // Statistics of the task graphs of the steps, if TaskGraph is set
+	// Workers of the next run of every phase, if the executor is Elastic
+	Workers map[string]int
+}
+
+// return the softening kernel of the configuration
+func newSofteningKernel(config Config) nbody.SofteningKernel {
+	name, length := config.SofteningKernel, config.SofteningLength
+	if name == "" {
+		name = "plummer"
+	}
+	if length <= 0 {
+		length = 0.01
+	}
+
+	kernel, err := nbody.NewSofteningKernel(name, length)
+	if err != nil {
+		fmt.Println("INVALID SOFTENING CONFIGURATION")
+		panic(err)
+	}
+	return kernel
+}
+
+// return the physics of the configuration
+func newPhysics(config Config) *nbody.Physics {
+	physics := nbody.NewPhysics(newSofteningKernel(config))
+	if config.BoxSize > 0 {
+		physics.Box = nbody.Box{Size: config.BoxSize}
+		if config.Ewald {
+			physics.Ewald = nbody.NewEwaldTable(config.BoxSize, 16)
+		}
+	}
+
+	external, err := nbody.NewExternalPotentials(config.External)
+	if err != nil {
+		fmt.Println("INVALID EXTERNAL POTENTIAL CONFIGURATION")
+		panic(err)
+	}
+	physics.External = external
+
+	law, err := nbody.ParseForceLaw(config.ForceLaw, physics.Kernel)
+	if err != nil {
+		fmt.Println("INVALID FORCE LAW CONFIGURATION")
+		panic(err)
+	}
+	physics.Law = law
+	return physics
+}
+
+// set the per body state of the force law, e.g. charges
+func initForceLaw(physics *nbody.Physics, bodies []*nbody.Body, numBodies int) {
+	if law, ok := physics.Law.(nbody.BodyInitializer); ok {
+		for i := 0; i < numBodies; i++ {
+			law.InitBody(i, bodies)
+		}
+	}
 }
 
 // Run the correct version based on the Mode field of the configuration value
-func Schedule(config Config) {
+func Schedule(config Config) Stats {
 	if config.Mode == "s" {
-		RunSequential(
-			config.NBodies,
-			config.Iterations,
-			0.01,
-			config.RecordPositions,
-		)
+		return RunSequential(config, 0.01)
 	} else if config.Mode == "ws" || config.Mode == "wb" {
-		RunParallel(
-			config.NBodies,
-			config.Iterations,
-			0.01,
-			config.RecordPositions,
-			config.ThreadCount,
-			config.Mode,
-		)
+		return RunParallel(config, 0.01)
 	} else {
 		panic("Invalid scheduling scheme: " + config.Mode)
 	}
@@ -0,0 +1,301 @@
+package scheduler
+
+import (
+	"proj3/nbody"
+)
+
+// Simulation is a run of a configuration that is advanced step by step
+// from Go code, the phases of every step run on its backend. It belongs to
+// this package rather than to nbody because it needs the solvers and the
+// backends of this package, which imports nbody.
+type Simulation struct {
+	config     Config
+	engine     Backend
+	physics    *nbody.Physics
+	solver     solver
+	controller *nbody.TimestepController
+	blocks     *nbody.BlockTimesteps
+	collisions bool
+	live       *liveView
+	recorder   *recorder
+	observers  []*observer
+	bodies     []*nbody.Body
+	active     []int
+	frozen     []*nbody.Body // Copies of the bodies the forces of a task graph read
+	dt         float32
+	step       int
+	time       float32
+	nextID     int
+	stats      Stats
+	// Steps between snapshots and the step of the last one
+	snapshotEvery, lastSnapshot int
+}
+
+// Snapshot is a copy of the state of a simulation
+type Snapshot struct {
+	Step   int
+	Time   float32
+	Bodies []nbody.BodyState
+}
+
+// return a simulation of the configuration with its NBodies initial bodies
+// on the backend of its mode: "s" (or "") computes every phase on the
+// calling goroutine, "ws" and "wb" on a new executor of the same name
+func New(config Config) *Simulation {
+	return newSimulation(config, newBackend(config), 0.01)
+}
+
+// return a simulation of the configuration with its NBodies initial bodies
+// on a backend
+func NewWithBackend(config Config, backend Backend) *Simulation {
+	return newSimulation(config, backend, 0.01)
+}
+
+// return a simulation with the fixed timestep dt, the recorder of the
+// snapshots and config.OnStep are its first observers
+func newSimulation(config Config, e Backend, dt float32) *Simulation {
+	s := &Simulation{
+		config:     config,
+		engine:     e,
+		physics:    newPhysics(config),
+		controller: newTimestepController(config, dt),
+		blocks:     newBlockTimesteps(config, dt),
+		collisions: collisionsEnabled(config),
+		bodies:     make([]*nbody.Body, config.NBodies),
+		dt:         dt,
+		nextID:     config.NBodies,
+
+		snapshotEvery: config.SnapshotEvery,
+		lastSnapshot:  -1,
+	}
+	if s.snapshotEvery <= 0 {
+		s.snapshotEvery = config.Iterations / 10
+	}
+	if s.snapshotEvery <= 0 {
+		s.snapshotEvery = 1
+	}
+	s.solver = newSolver(config, s.physics, e.Chunks())
+
+	e.InitBodies(s.bodies, len(s.bodies))
+	initForceLaw(s.physics, s.bodies, len(s.bodies))
+	if s.collisions {
+		for i := range s.bodies {
+			nbody.SetRadius(i, s.bodies, config.BodyRadius)
+		}
+	}
+
+	s.diagnose()
+	s.live = newLiveView(config, s.physics, e.Executor(), e.Chunks())
+	if s.live != nil {
+		s.live.start(s.bodies, len(s.bodies))
+	}
+
+	// WRITE POSITIONS
+	if config.RecordPositions == "yes" {
+		s.recorder = newRecorder(config, recordDir(config))
+		s.Observe(s.recorder.observer())
+	}
+	if config.OnStep != nil {
+		config.OnStep(0, 0, s.bodies, len(s.bodies))
+		s.Observe(Observer{AfterStep: func(v View) {
+			config.OnStep(v.Step, v.Time, v.bodies, len(v.bodies))
+		}})
+	}
+	return s
+}
+
+// Observe registers the hooks of an observer for the following steps
+func (s *Simulation) Observe(o Observer) {
+	s.observers = append(s.observers, newObserver(o))
+}
+
+// call the hooks of a kind of every observer with the current state
+func (s *Simulation) notify(kind int) {
+	view := View{s.step, s.time, s.bodies}
+	for _, obs := range s.observers {
+		obs.notify(kind, view)
+	}
+}
+
+// notify the snapshot hooks if the current step is due for a snapshot
+func (s *Simulation) snapshot() {
+	if s.step%s.snapshotEvery == 0 && s.lastSnapshot != s.step {
+		s.lastSnapshot = s.step
+		s.notify(hookSnapshot)
+	}
+}
+
+// Step advances the simulation by n steps
+func (s *Simulation) Step(n int) {
+	for ; n > 0; n-- {
+		s.advance()
+	}
+}
+
+// advance the bodies by one step and notify the observers
+func (s *Simulation) advance() {
+	s.snapshot()
+	s.notify(hookBeforeStep)
+
+	numBodies := len(s.bodies)
+	physics, solver, executor := s.physics, s.solver, s.engine.Executor()
+
+	diagnosed := false
+	if s.graphStepEnabled() {
+		diagnosed = s.graphStep()
+	} else if s.blocks != nil {
+		blocks := s.blocks
+		for sub := 0; sub < blocks.Substeps(); sub++ {
+			// ONLY BODIES WHOSE STEP STARTS NOW NEED NEW FORCES
+			s.active = blocks.Active(sub, s.bodies, numBodies, s.active[:0])
+			if solver != nil {
+				solver.accelerations(executor, s.bodies, numBodies, s.active)
+			} else {
+				s.engine.Accelerations(s.bodies, numBodies, physics, s.active)
+			}
+			s.notify(hookAfterForce)
+
+			// KICKS AND DRIFTS ARE O(N) AND CHEAPER THAN SUBMITTING TASKS
+			for _, i := range s.active {
+				blocks.AssignLevel(i, s.bodies, sub)
+				nbody.KickBody(i, s.bodies, blocks.LevelDt(s.bodies[i].Level()))
+			}
+
+			for i := 0; i < numBodies; i++ {
+				nbody.IntegratePositions(i, s.bodies, numBodies, blocks.SubstepDt(), physics.Box)
+			}
+
+			s.stats.Substeps++
+			s.stats.ActiveUpdates += int64(len(s.active))
+		}
+		s.dt = blocks.Controller.DtMax
+	} else if s.controller == nil && (solver != nil || physics.Law != nil) {
+		// A FORCE LAW MAY READ THE VELOCITIES, SO NO BODY IS KICKED BEFORE EVERY ACCELERATION IS DONE
+		if solver != nil {
+			solver.accelerations(executor, s.bodies, numBodies, nil)
+		} else {
+			s.engine.Accelerations(s.bodies, numBodies, physics, nil)
+		}
+		s.notify(hookAfterForce)
+		s.engine.KickAndIntegrate(s.bodies, numBodies, s.dt, physics.Box)
+	} else if s.controller == nil {
+		s.engine.Forces(s.bodies, numBodies, s.dt, physics)
+		s.notify(hookAfterForce)
+		s.engine.Integrate(s.bodies, numBodies, s.dt, physics.Box)
+	} else {
+		if solver != nil {
+			solver.accelerations(executor, s.bodies, numBodies, nil)
+		} else {
+			s.engine.Accelerations(s.bodies, numBodies, physics, nil)
+		}
+		s.notify(hookAfterForce)
+
+		// CHOOSE THE TIMESTEP FROM THE LARGEST ACCELERATION
+		maxAcceleration := parallelMaxAcceleration(executor, s.bodies, numBodies, s.engine.Chunks())
+		s.dt = s.controller.Timestep(maxAcceleration)
+		s.stats.Timesteps = append(s.stats.Timesteps, Timestep{s.step, s.time, s.dt, maxAcceleration})
+
+		s.engine.KickAndIntegrate(s.bodies, numBodies, s.dt, physics.Box)
+	}
+	s.step++
+	s.time += s.dt
+
+	if s.collisions {
+		s.bodies = collide(s.config, executor, 4*s.engine.Chunks(), physics.Box, s.bodies, numBodies, s.step, s.time, &s.stats)
+		numBodies = len(s.bodies)
+	}
+
+	if !diagnosed {
+		s.diagnose()
+	}
+	if s.live != nil {
+		s.live.update(s.step, s.bodies, numBodies)
+	}
+	s.notify(hookAfterStep)
+}
+
+// record the global diagnostics if the current step is due for them
+func (s *Simulation) diagnose() {
+	if every := s.config.DiagnosticsEvery; every > 0 && s.step%every == 0 {
+		s.stats.Globals = append(s.stats.Globals, globalDiagnostic(s.engine.Executor(), s.bodies, len(s.bodies),
+			s.physics, s.engine.Chunks(), s.step, s.time))
+	}
+}
+
+// State returns a copy of the current state
+func (s *Simulation) State() Snapshot {
+	snapshot := Snapshot{Step: s.step, Time: s.time, Bodies: make([]nbody.BodyState, len(s.bodies))}
+	for i, b := range s.bodies {
+		snapshot.Bodies[i] = b.State()
+	}
+	return snapshot
+}
+
+// AddBody adds a body with the given state and returns its id, the id of
+// the state is ignored. Bodies without a radius get the configured one and,
+// if the force law sets per body state, bodies without a charge get theirs
+// from the force law.
+func (s *Simulation) AddBody(state nbody.BodyState) int {
+	state.ID = s.nextID
+	s.nextID++
+	if s.collisions && state.Radius == 0 {
+		state.Radius = s.config.BodyRadius
+	}
+
+	s.bodies = append(s.bodies, nbody.NewBodyFromState(state))
+	if law, ok := s.physics.Law.(nbody.BodyInitializer); ok && state.Charge == 0 {
+		law.InitBody(len(s.bodies)-1, s.bodies)
+	}
+	return state.ID
+}
+
+// RemoveBody removes the body with the given id, it returns false if there
+// is none
+func (s *Simulation) RemoveBody(id int) bool {
+	for i, b := range s.bodies {
+		if b.ID() == id {
+			s.bodies = append(s.bodies[:i], s.bodies[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns what happened during the steps so far
+func (s *Simulation) Stats() Stats {
+	stats := s.stats
+	stats.Survivors = len(s.bodies)
+	if s.blocks != nil {
+		stats.Levels = levelHistogram(s.blocks, s.bodies, len(s.bodies))
+	}
+	if e, ok := s.engine.(*executorEngine); ok && e.scaler != nil {
+		stats.Workers = e.scaler.Workers()
+	}
+	return stats
+}
+
+// Run simulates the configuration on a backend and returns its stats. Like
+// the first runners it takes Iterations + 1 steps and records snapshots of
+// the first Iterations.
+func Run(config Config, backend Backend, dt float32) Stats {
+	s := newSimulation(config, backend, dt)
+	s.Step(config.Iterations + 1)
+	s.lastSnapshot = s.step // THE EXTRA STEP IS NOT RECORDED
+	s.Close()
+	return s.Stats()
+}
+
+// Close takes the last snapshot if it is due, calls the finish hooks,
+// waits for the asynchronous observers and stops the executor. The
+// simulation must not be stepped afterwards.
+func (s *Simulation) Close() {
+	s.snapshot()
+	s.notify(hookFinish)
+	for _, obs := range s.observers {
+		obs.close()
+	}
+	if s.recorder != nil {
+		s.stats.Snapshots = s.recorder.Stats()
+	}
+	s.engine.Shutdown()
+}
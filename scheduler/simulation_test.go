@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"testing"
+
+	"proj3/nbody"
+)
+
+// return the ids of the bodies of a state in order
+func stateIDs(state Snapshot) []int {
+	ids := make([]int, len(state.Bodies))
+	for i, body := range state.Bodies {
+		ids[i] = body.ID
+	}
+	return ids
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSimulationStep(t *testing.T) {
+	for _, mode := range []string{"s", "ws", "wb"} {
+		t.Run(mode, func(t *testing.T) {
+			s := New(Config{Mode: mode, NBodies: 6, ThreadCount: 2, RecordPositions: "no"})
+			defer s.Close()
+
+			state := s.State()
+			if state.Step != 0 || !equalIDs(stateIDs(state), []int{0, 1, 2, 3, 4, 5}) {
+				t.Fatalf("initial step %d with the bodies %v", state.Step, stateIDs(state))
+			}
+			s.Step(3)
+			state = s.State()
+			if state.Step != 3 || state.Time <= 0 {
+				t.Fatalf("step %d at time %g after 3 steps", state.Step, state.Time)
+			}
+			// THE STATE IS A COPY
+			state.Bodies[0].X = 1e9
+			if s.State().Bodies[0].X == 1e9 {
+				t.Fatal("changing the state changed the simulation")
+			}
+		})
+	}
+}
+
+// ids are never reused, removing a body leaves the others with their ids
+// and removing an unknown id fails
+func TestSimulationAddRemoveBody(t *testing.T) {
+	s := New(Config{NBodies: 3, RecordPositions: "no"})
+	defer s.Close()
+
+	added := nbody.BodyState{ID: 99, X: 5, Y: -5, Mass: 2}
+	if id := s.AddBody(added); id != 3 {
+		t.Fatalf("added body %d, want 3", id)
+	}
+	state := s.State()
+	if body := state.Bodies[3]; body.ID != 3 || body.X != 5 || body.Y != -5 || body.Mass != 2 {
+		t.Fatalf("added body %+v", body)
+	}
+
+	if !s.RemoveBody(1) {
+		t.Fatal("body 1 was not removed")
+	}
+	if ids := stateIDs(s.State()); !equalIDs(ids, []int{0, 2, 3}) {
+		t.Fatalf("bodies %v after removing 1", ids)
+	}
+	if s.RemoveBody(1) || s.RemoveBody(42) {
+		t.Fatal("removed a body that does not exist")
+	}
+	if id := s.AddBody(added); id != 4 {
+		t.Fatalf("added body %d after a removal, want 4", id)
+	}
+
+	s.Step(2)
+	if ids := stateIDs(s.State()); !equalIDs(ids, []int{0, 2, 3, 4}) {
+		t.Fatalf("bodies %v after stepping", ids)
+	}
+}
+
+// backend counting the phases it runs on the sequential backend
+type countingBackend struct {
+	Backend
+	forces, integrations int
+}
+
+func (b *countingBackend) Forces(bodies []*nbody.Body, numBodies int, dt float32, physics *nbody.Physics) {
+	b.forces++
+	b.Backend.Forces(bodies, numBodies, dt, physics)
+}
+
+func (b *countingBackend) Integrate(bodies []*nbody.Body, numBodies int, dt float32, box nbody.Box) {
+	b.integrations++
+	b.Backend.Integrate(bodies, numBodies, dt, box)
+}
+
+// a backend implemented outside the package runs every phase of the steps
+// and gives the bodies of the sequential backend
+func TestSimulationCustomBackend(t *testing.T) {
+	config := Config{NBodies: 10, RecordPositions: "no"}
+	initial := New(config)
+	bodies := initial.State().Bodies
+	initial.Close()
+
+	backend := &countingBackend{Backend: NewSequentialBackend()}
+	final := finalBodies(config, backend, bodies, 4)
+	reference := finalBodies(config, NewSequentialBackend(), bodies, 4)
+	if backend.forces != 4 || backend.integrations != 4 {
+		t.Fatalf("%d force and %d integration phases in 4 steps", backend.forces, backend.integrations)
+	}
+	for id, body := range reference {
+		if final[id] != body {
+			t.Fatalf("body %d is %+v, the sequential backend gives %+v", id, final[id], body)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"proj3/concurrent"
+	"proj3/nbody"
+)
+
+// solver computing the accelerations of many bodies at once, in place of
+// one direct sum task per body
+type solver interface {
+	// set the accelerations of the bodies ids, or of all bodies if ids is
+	// nil, with tasks on the executor if it is not nil
+	accelerations(executor concurrent.ExecutorService, bodies []*nbody.Body, numBodies int, ids []int)
+}
+
+// return the solver of the configuration, or nil if forces are computed with
+// the direct sum. chunks is the number of tasks of every parallel pass.
+func newSolver(config Config, physics *nbody.Physics, chunks int) solver {
+	if config.Solver == "" || config.Solver == "direct" {
+		return nil
+	}
+	if physics.Law != nil {
+		panic("The " + config.Solver + " solver only computes gravity, not the force law " + physics.Law.Name())
+	}
+
+	switch config.Solver {
+	case "pm":
+		return newMeshSolver(config, physics, chunks)
+	case "fmm":
+		return newFMMSolver(config, physics, chunks)
+	}
+	panic("Invalid force solver: " + config.Solver)
+}
@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"proj3/concurrent"
+	"proj3/nbody"
+)
+
+// Phase is the work of an NbodyTask on its body, new phases are functions
+// with this signature
+type Phase func(id int, bodies []*nbody.Body, numBodies int, dt float32, physics *nbody.Physics)
+
+// compute interbody forces and update the velocity
+func ComputeForce(id int, bodies []*nbody.Body, numBodies int, dt float32, physics *nbody.Physics) {
+	nbody.ComputeBodyForce(id, bodies, dt, numBodies, physics)
+}
+
+// compute interbody forces without updating the velocity
+func ComputeAcceleration(id int, bodies []*nbody.Body, numBodies int, dt float32, physics *nbody.Physics) {
+	nbody.ComputeBodyAcceleration(id, bodies, numBodies, physics)
+}
+
+// integrate the position in the box of the physics
+func IntegratePositions(id int, bodies []*nbody.Body, numBodies int, dt float32, physics *nbody.Physics) {
+	nbody.IntegratePositions(id, bodies, numBodies, dt, physics.Box)
+}
+
+// update the velocity, then integrate the position
+func KickAndIntegratePositions(id int, bodies []*nbody.Body, numBodies int, dt float32, physics *nbody.Physics) {
+	nbody.KickBody(id, bodies, dt)
+	nbody.IntegratePositions(id, bodies, numBodies, dt, physics.Box)
+}
+
+// create the body with random initial position and velocity, physics may
+// be nil
+func InitPositionsAndVelocities(id int, bodies []*nbody.Body, numBodies int, dt float32, physics *nbody.Physics) {
+	nbody.InitPositionsAndVelocities(id, bodies, numBodies)
+}
+
+// runnable task running one phase on one body
+type NbodyTask struct {
+	id        int
+	bodies    []*nbody.Body
+	dt        float32
+	numBodies int
+	physics   *nbody.Physics
+	phase     Phase
+}
+
+func NewNbodyTask(id int, bodies []*nbody.Body, dt float32,
+	numBodies int, physics *nbody.Physics, phase Phase) concurrent.Runnable {
+	return &NbodyTask{
+		id:        id,
+		bodies:    bodies,
+		dt:        dt,
+		numBodies: numBodies,
+		physics:   physics,
+		phase:     phase,
+	}
+}
+
+func (task *NbodyTask) Run() {
+	task.phase(task.id, task.bodies, task.numBodies, task.dt, task.physics)
+}
+
+// kinetic and potential energy of a range of bodies
+type energyParts struct {
+	kinetic, potential float64
+}
+
+// callable task returning the energyParts of a chunk of bodies, the
+// potential energy of a body counts its pairs with the bodies after it
+type energyTask struct {
+	bodies     []*nbody.Body
+	numBodies  int
+	physics    *nbody.Physics
+	start, end int
+}
+
+func (task *energyTask) Call() energyParts {
+	parts := energyParts{kinetic: nbody.KineticEnergy(task.bodies[task.start:task.end], task.end-task.start)}
+	for i := task.start; i < task.end; i++ {
+		parts.potential += nbody.BodyPotentialEnergy(i, task.bodies, task.numBodies, task.physics)
+	}
+	return parts
+}
+
+// callable task returning the bounding box of a chunk of bodies
+type boundsTask struct {
+	bodies     []*nbody.Body
+	start, end int
+}
+
+func (task *boundsTask) Call() nbody.BoundingBox {
+	return nbody.Bounds(task.bodies, task.start, task.end)
+}
+
+// callable task returning the moments of a chunk of bodies
+type momentsTask struct {
+	bodies     []*nbody.Body
+	start, end int
+}
+
+func (task *momentsTask) Call() nbody.Moments {
+	return nbody.BodyMoments(task.bodies, task.start, task.end)
+}
+
+// sum the kinetic and potential energy of the bodies
+func parallelEnergy(executor concurrent.ExecutorService, bodies []*nbody.Body, numBodies int,
+	physics *nbody.Physics, chunks int) (kinetic, potential float64) {
+	// BODIES NEAR THE START HAVE MORE PAIRS, SO USE SMALL CHUNKS
+	parts := Reduce(executor, numBodies, 4*chunks, func(start, end int) concurrent.TypedCallable[energyParts] {
+		return &energyTask{bodies, numBodies, physics, start, end}
+	}, energyParts{}, addEnergyParts)
+	return parts.kinetic, parts.potential
+}
+
+// combine the energyParts of two ranges
+func addEnergyParts(a, b energyParts) energyParts {
+	return energyParts{a.kinetic + b.kinetic, a.potential + b.potential}
+}
+
+// return the bounding box of the bodies
+func parallelBounds(executor concurrent.ExecutorService, bodies []*nbody.Body, numBodies, chunks int) nbody.BoundingBox {
+	return Reduce(executor, numBodies, chunks, func(start, end int) concurrent.TypedCallable[nbody.BoundingBox] {
+		return &boundsTask{bodies, start, end}
+	}, nbody.EmptyBoundingBox(), nbody.BoundingBox.Union)
+}
+
+// sum the moments of the bodies
+func parallelMoments(executor concurrent.ExecutorService, bodies []*nbody.Body, numBodies, chunks int) nbody.Moments {
+	return Reduce(executor, numBodies, chunks, func(start, end int) concurrent.TypedCallable[nbody.Moments] {
+		return &momentsTask{bodies, start, end}
+	}, nbody.Moments{}, nbody.Moments.Add)
+}
@@ -0,0 +1,249 @@
+// Package server exposes a running simulation over HTTP: the run status and
+// configuration as JSON, body positions as a stream of Server-Sent Events,
+// and a bundled canvas page that draws the bodies in 3D.
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"proj3/nbody"
+)
+
+//go:embed static
+var static embed.FS
+
+// Options controls what is streamed to viewers
+type Options struct {
+	FPS       int // Maximum frames per second sent to viewers
+	MaxBodies int // Bodies per frame, larger runs are subsampled
+}
+
+// DefaultOptions returns the options used by the editor
+func DefaultOptions() Options {
+	return Options{FPS: 10, MaxBodies: 5000}
+}
+
+// Status is served as JSON on /api/status
+type Status struct {
+	Running     bool    `json:"running"`
+	Step        int     `json:"step"`
+	Iterations  int     `json:"iterations"`
+	Time        float32 `json:"time"`
+	Bodies      int     `json:"bodies"`
+	Elapsed     float64 `json:"elapsed"`
+	StepsPerSec float64 `json:"stepsPerSec"`
+	Viewers     int     `json:"viewers"`
+}
+
+// Server streams frames published by a simulation to browsers
+type Server struct {
+	opts     Options
+	config   []byte
+	listener net.Listener
+	http     *http.Server
+
+	lock      sync.Mutex
+	status    Status
+	started   time.Time
+	lastFrame time.Time
+	frame     []byte // Last encoded frame, sent to new viewers
+	viewers   map[chan []byte]bool
+}
+
+// return a new server for a run of the given number of iterations, config
+// is served as JSON on /api/config
+func New(opts Options, config interface{}, iterations int) (*Server, error) {
+	encoded, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if opts.FPS <= 0 {
+		opts.FPS = 10
+	}
+	return &Server{
+		opts:    opts,
+		config:  encoded,
+		status:  Status{Running: true, Iterations: iterations},
+		started: time.Now(),
+		viewers: make(map[chan []byte]bool),
+	}, nil
+}
+
+// Start listens on addr and serves requests on a background goroutine
+func (s *Server) Start(addr string) error {
+	content, err := fs.Sub(static, "static")
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(content)))
+	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("/api/config", s.handleConfig)
+	mux.HandleFunc("/api/stream", s.handleStream)
+
+	s.listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.http = &http.Server{Handler: mux}
+	go s.http.Serve(s.listener)
+	return nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Publish records the progress of the run and, at most FPS times per
+// second, sends the body positions to every viewer. Frames are dropped for
+// viewers that cannot keep up, so Publish never blocks on the network.
+func (s *Server) Publish(step int, t float32, bodies []*nbody.Body, numBodies int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.status.Step, s.status.Time, s.status.Bodies = step, t, numBodies
+	s.status.Elapsed = now.Sub(s.started).Seconds()
+	if s.status.Elapsed > 0 {
+		s.status.StepsPerSec = float64(step) / s.status.Elapsed
+	}
+
+	if now.Sub(s.lastFrame) < time.Second/time.Duration(s.opts.FPS) {
+		return
+	}
+	s.lastFrame = now
+	s.frame = s.encodeFrame(step, t, bodies, numBodies)
+
+	for viewer := range s.viewers {
+		select {
+		case viewer <- s.frame:
+		default:
+		}
+	}
+}
+
+// encode the positions as an SSE event, subsampling to MaxBodies bodies
+func (s *Server) encodeFrame(step int, t float32, bodies []*nbody.Body, numBodies int) []byte {
+	stride := 1
+	if s.opts.MaxBodies > 0 && numBodies > s.opts.MaxBodies {
+		stride = (numBodies + s.opts.MaxBodies - 1) / s.opts.MaxBodies
+	}
+
+	buf := make([]byte, 0, 64+24*(numBodies/stride+1))
+	buf = append(buf, "event: frame\ndata: {\"step\":"...)
+	buf = strconv.AppendInt(buf, int64(step), 10)
+	buf = append(buf, ",\"time\":"...)
+	buf = strconv.AppendFloat(buf, float64(t), 'g', 6, 32)
+	buf = append(buf, ",\"stride\":"...)
+	buf = strconv.AppendInt(buf, int64(stride), 10)
+	buf = append(buf, ",\"positions\":["...)
+	for i := 0; i < numBodies; i += stride {
+		for c, field := range [3]string{"x", "y", "z"} {
+			if i > 0 || c > 0 {
+				buf = append(buf, ',')
+			}
+			v, _ := bodies[i].Value(field)
+			buf = strconv.AppendFloat(buf, float64(v), 'f', 2, 32)
+		}
+	}
+	buf = append(buf, "]}\n\n"...)
+	return buf
+}
+
+// Finish marks the run as completed and ends the streams of all viewers
+func (s *Server) Finish() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.status.Running = false
+	for viewer := range s.viewers {
+		close(viewer)
+		delete(s.viewers, viewer)
+	}
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	s.Finish()
+	return s.http.Close()
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	status := s.status
+	status.Viewers = len(s.viewers)
+	s.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.config)
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	viewer := make(chan []byte, 1)
+	s.lock.Lock()
+	if !s.status.Running {
+		s.lock.Unlock()
+		http.Error(w, "simulation finished", http.StatusGone)
+		return
+	}
+	s.viewers[viewer] = true
+	last := s.frame
+	s.lock.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	if last != nil {
+		w.Write(last)
+	} else {
+		fmt.Fprint(w, ": waiting for the first frame\n\n")
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case frame, ok := <-viewer:
+			if !ok {
+				fmt.Fprint(w, "event: finished\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+			if _, err := w.Write(frame); err != nil {
+				s.removeViewer(viewer)
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			s.removeViewer(viewer)
+			return
+		}
+	}
+}
+
+func (s *Server) removeViewer(viewer chan []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.viewers[viewer] {
+		close(viewer)
+		delete(s.viewers, viewer)
+	}
+}
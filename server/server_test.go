@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"proj3/nbody"
+)
+
+// return a started server of a run of 10 iterations and its base url
+func startServer(t *testing.T) (*Server, string) {
+	config := map[string]interface{}{"NBodies": 2, "Mode": "ws"}
+	s, err := New(Options{FPS: 1000, MaxBodies: 10}, config, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, "http://" + s.Addr()
+}
+
+// fetch url and decode its JSON body into v
+func getJSON(t *testing.T, url string, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("%s: content type %q", url, ct)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("%s: %v", url, err)
+	}
+}
+
+func TestStatusAndConfig(t *testing.T) {
+	s, url := startServer(t)
+	bodies := []*nbody.Body{nbody.NewBodyFromState(nbody.BodyState{X: 1, Mass: 1})}
+	s.Publish(3, 0.5, bodies, len(bodies))
+
+	var status Status
+	getJSON(t, url+"/api/status", &status)
+	if !status.Running || status.Step != 3 || status.Iterations != 10 || status.Bodies != 1 {
+		t.Fatalf("status %+v", status)
+	}
+
+	var config map[string]interface{}
+	getJSON(t, url+"/api/config", &config)
+	if config["NBodies"] != 2.0 || config["Mode"] != "ws" {
+		t.Fatalf("config %v", config)
+	}
+}
+
+// a viewer gets the frames published after it connects and the end of the
+// stream when the run finishes
+func TestStream(t *testing.T) {
+	s, url := startServer(t)
+	resp, err := http.Get(url + "/api/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+
+	lines := make(chan string, 16)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	next := func() string {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("the stream ended")
+			}
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event from the stream")
+			return ""
+		}
+	}
+	if line := next(); !strings.HasPrefix(line, ":") {
+		t.Fatalf("first line %q, want a comment while no frame is published", line)
+	}
+
+	bodies := []*nbody.Body{nbody.NewBodyFromState(nbody.BodyState{X: 1, Y: 2, Z: 3, Mass: 1})}
+	s.Publish(1, 0.25, bodies, len(bodies))
+	for line := next(); line != "event: frame"; line = next() {
+	}
+	data := strings.TrimPrefix(next(), "data: ")
+	var frame struct {
+		Step      int
+		Positions []float64
+	}
+	if err := json.Unmarshal([]byte(data), &frame); err != nil {
+		t.Fatalf("frame %q: %v", data, err)
+	}
+	if frame.Step != 1 || len(frame.Positions) != 3 || frame.Positions[2] != 3 {
+		t.Fatalf("frame %+v", frame)
+	}
+
+	s.Finish()
+	for line := next(); line != "event: finished"; line = next() {
+	}
+}
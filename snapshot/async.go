@@ -0,0 +1,116 @@
+package snapshot
+
+import (
+	"sync"
+	"time"
+
+	"proj3/nbody"
+)
+
+// BodyWriter is implemented by nbody.CSVWriter, Writer and AsyncWriter
+type BodyWriter interface {
+	WriteFrame(step int, time float32, bodies []*nbody.Body, numBodies int) error
+}
+
+// Stats reports where the time spent on snapshots went
+type Stats struct {
+	Frames  int
+	Capture time.Duration // copying body state, on the caller's goroutine
+	Stall   time.Duration // waiting for a free buffer, on the caller's goroutine
+	Write   time.Duration // serializing frames, on the background goroutine
+}
+
+type asyncFrame struct {
+	step      int
+	time      float32
+	numBodies int
+	bodies    []*nbody.Body
+	storage   []nbody.Body
+}
+
+// AsyncWriter copies body state into one of two buffers and serializes it
+// on a background goroutine, so the caller only pays for the copy. At most
+// two frames are held in memory; WriteFrame blocks while both are in use.
+type AsyncWriter struct {
+	dst   BodyWriter
+	free  chan *asyncFrame
+	full  chan *asyncFrame
+	done  chan struct{}
+	lock  sync.Mutex
+	err   error
+	stats Stats
+}
+
+// return a new asynchronous writer that serializes frames with dst
+func NewAsyncWriter(dst BodyWriter) *AsyncWriter {
+	aw := &AsyncWriter{
+		dst:  dst,
+		free: make(chan *asyncFrame, 2),
+		full: make(chan *asyncFrame, 2),
+		done: make(chan struct{}),
+	}
+	aw.free <- &asyncFrame{}
+	aw.free <- &asyncFrame{}
+
+	go func() {
+		defer close(aw.done)
+		for f := range aw.full {
+			start := time.Now()
+			err := aw.dst.WriteFrame(f.step, f.time, f.bodies, f.numBodies)
+			elapsed := time.Since(start)
+
+			aw.lock.Lock()
+			aw.stats.Write += elapsed
+			if aw.err == nil {
+				aw.err = err
+			}
+			aw.lock.Unlock()
+
+			aw.free <- f
+		}
+	}()
+	return aw
+}
+
+// copy the first numBodies bodies and queue them for writing, errors from
+// earlier frames are returned by later calls and by Close
+func (aw *AsyncWriter) WriteFrame(step int, t float32, bodies []*nbody.Body, numBodies int) error {
+	start := time.Now()
+	f := <-aw.free
+	acquired := time.Now()
+
+	if cap(f.storage) < numBodies {
+		f.storage = make([]nbody.Body, numBodies)
+		f.bodies = make([]*nbody.Body, numBodies)
+	}
+	f.storage, f.bodies = f.storage[:numBodies], f.bodies[:numBodies]
+	for i := 0; i < numBodies; i++ {
+		f.storage[i] = *bodies[i]
+		f.bodies[i] = &f.storage[i]
+	}
+	f.step, f.time, f.numBodies = step, t, numBodies
+	aw.full <- f
+
+	aw.lock.Lock()
+	defer aw.lock.Unlock()
+	aw.stats.Frames++
+	aw.stats.Stall += acquired.Sub(start)
+	aw.stats.Capture += time.Since(acquired)
+	return aw.err
+}
+
+// Close waits for the queued frames to be written
+func (aw *AsyncWriter) Close() error {
+	close(aw.full)
+	<-aw.done
+	aw.lock.Lock()
+	defer aw.lock.Unlock()
+	return aw.err
+}
+
+// Stats returns the time spent on snapshots so far
+func (aw *AsyncWriter) Stats() Stats {
+	aw.lock.Lock()
+	defer aw.lock.Unlock()
+	return aw.stats
+}
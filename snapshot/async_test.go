@@ -0,0 +1,55 @@
+package snapshot
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"proj3/nbody"
+)
+
+// writer recording the step and first id of every frame, slowly
+type slowWriter struct {
+	lock  sync.Mutex
+	steps []int
+	ids   []int
+}
+
+func (sw *slowWriter) WriteFrame(step int, _ float32, bodies []*nbody.Body, numBodies int) error {
+	time.Sleep(time.Millisecond)
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	sw.steps = append(sw.steps, step)
+	sw.ids = append(sw.ids, bodies[0].State().ID)
+	return nil
+}
+
+// every frame queued is written, in order, by the time Close returns, and
+// later changes to the bodies do not reach frames already queued
+func TestAsyncWriterClose(t *testing.T) {
+	const frames = 20
+	dst := &slowWriter{}
+	aw := NewAsyncWriter(dst)
+	bodies := testBodies()
+	for step := 0; step < frames; step++ {
+		*bodies[0] = *nbody.NewBodyFromState(nbody.BodyState{ID: step, Mass: 1})
+		if err := aw.WriteFrame(step, 0, bodies, len(bodies)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dst.steps) != frames {
+		t.Fatalf("%d frames written, want %d", len(dst.steps), frames)
+	}
+	for step := 0; step < frames; step++ {
+		if dst.steps[step] != step || dst.ids[step] != step {
+			t.Fatalf("frame %d written as step %d of body %d", step, dst.steps[step], dst.ids[step])
+		}
+	}
+	if stats := aw.Stats(); stats.Frames != frames {
+		t.Fatalf("stats count %d frames, want %d", stats.Frames, frames)
+	}
+}
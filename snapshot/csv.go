@@ -0,0 +1,182 @@
+package snapshot
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVReader reads the csv files written by nbody.CSVWriter, grouping
+// consecutive rows with the same step into frames
+type CSVReader struct {
+	s       *bufio.Scanner
+	columns []string
+	fields  []int // column index of every body field
+	step    int   // column index of step, or -1
+	time    int   // column index of time, or -1
+	pending []string
+	line    int
+}
+
+// return a new csv reader, the header row is read on the first call to Next
+func NewCSVReader(r io.Reader) *CSVReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &CSVReader{s: s, step: -1, time: -1}
+}
+
+func (cr *CSVReader) readRow() ([]string, error) {
+	for cr.s.Scan() {
+		cr.line++
+		line := strings.TrimSpace(cr.s.Text())
+		if line == "" {
+			continue
+		}
+		row := strings.Split(line, ",")
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+		return row, nil
+	}
+	if err := cr.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (cr *CSVReader) readHeader() error {
+	header, err := cr.readRow()
+	if err == io.EOF {
+		return errors.New("snapshot: empty csv file")
+	} else if err != nil {
+		return err
+	}
+
+	cr.columns = header
+	for i, column := range header {
+		switch column {
+		case "step":
+			cr.step = i
+		case "time":
+			cr.time = i
+		default:
+			if _, err := strconv.ParseFloat(column, 64); err == nil {
+				return errors.New("snapshot: csv file has no header row")
+			}
+			cr.fields = append(cr.fields, i)
+		}
+	}
+	if cr.step < 0 {
+		return errors.New("snapshot: csv file has no step column")
+	}
+	return nil
+}
+
+// Next reads the next frame, it returns io.EOF when there are no more frames
+func (cr *CSVReader) Next() (*Frame, error) {
+	if cr.columns == nil {
+		if err := cr.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := make([]string, len(cr.fields))
+	for i, c := range cr.fields {
+		fields[i] = cr.columns[c]
+	}
+	f := NewFrame(fields, 0)
+
+	for {
+		row := cr.pending
+		cr.pending = nil
+		if row == nil {
+			var err error
+			row, err = cr.readRow()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if len(row) != len(cr.columns) {
+			return nil, fmt.Errorf("snapshot: line %d has %d columns, expected %d", cr.line, len(row), len(cr.columns))
+		}
+
+		step, err := strconv.ParseInt(row[cr.step], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: line %d: bad step %q", cr.line, row[cr.step])
+		}
+		if f.N == 0 {
+			f.Step = step
+			if cr.time >= 0 {
+				if f.Time, err = strconv.ParseFloat(row[cr.time], 64); err != nil {
+					return nil, fmt.Errorf("snapshot: line %d: bad time %q", cr.line, row[cr.time])
+				}
+			}
+		} else if step != f.Step {
+			cr.pending = row
+			break
+		}
+
+		for _, c := range cr.fields {
+			v, err := strconv.ParseFloat(row[c], 32)
+			if err != nil {
+				return nil, fmt.Errorf("snapshot: line %d: bad %s value %q", cr.line, cr.columns[c], row[c])
+			}
+			f.Data = append(f.Data, float32(v))
+		}
+		f.N++
+	}
+
+	if f.N == 0 {
+		return nil, io.EOF
+	}
+	return f, nil
+}
+
+// CSVWriter writes frames in the csv format of nbody.CSVWriter
+type CSVWriter struct {
+	w           *bufio.Writer
+	precision   int
+	wroteHeader bool
+}
+
+// return a new csv frame writer, floats are written with precision digits
+// after the decimal point
+func NewCSVWriter(w io.Writer, precision int) *CSVWriter {
+	return &CSVWriter{w: bufio.NewWriter(w), precision: precision}
+}
+
+// Write writes one frame and flushes it to the underlying writer
+func (cw *CSVWriter) Write(f *Frame) error {
+	if !cw.wroteHeader {
+		header := append([]string{"step", "time"}, f.Fields...)
+		if _, err := cw.w.WriteString(strings.Join(header, ", ") + "\n"); err != nil {
+			return err
+		}
+		cw.wroteHeader = true
+	}
+
+	var row []byte
+	for i := 0; i < f.N; i++ {
+		row = strconv.AppendInt(row[:0], f.Step, 10)
+		row = append(row, ", "...)
+		row = strconv.AppendFloat(row, f.Time, 'e', cw.precision, 32)
+		for c, field := range f.Fields {
+			row = append(row, ", "...)
+			if field == "id" {
+				row = strconv.AppendInt(row, int64(f.Value(i, c)), 10)
+			} else {
+				row = strconv.AppendFloat(row, float64(f.Value(i, c)), 'e', cw.precision, 32)
+			}
+		}
+		row = append(row, '\n')
+		if _, err := cw.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return cw.w.Flush()
+}
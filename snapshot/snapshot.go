@@ -0,0 +1,372 @@
+// Package snapshot implements a compact little-endian binary format for
+// simulation snapshots and conversion to and from the csv format written
+// by nbody.CSVWriter.
+//
+// A snapshot file is a sequence of frames. Every frame starts with a header
+//
+//	offset  size  field
+//	0       4     magic "NBSF"
+//	4       2     format version (uint16)
+//	6       2     number of fields F (uint16)
+//	8       4     number of bodies N (uint32)
+//	12      4     header size H in bytes, including padding (uint32)
+//	16      8     step (int64)
+//	24      8     simulation time (float64)
+//	32      ...   F field names, each a uint8 length followed by the name
+//	...     ...   zero padding up to a multiple of 8 bytes
+//
+// followed by N records of F float32 values, one record per body in the
+// order of the field names. The frame occupies H + 4*N*F bytes, so frames
+// can be indexed by walking the headers and their data memory-mapped
+// directly as []float32. Body ids are stored as floats and are exact for
+// ids below 2^24.
+package snapshot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"proj3/nbody"
+)
+
+const (
+	Magic   = "NBSF"
+	Version = 1
+
+	fixedHeaderSize = 32
+
+	// Most bodies and most bytes of body records a frame may declare, so a
+	// corrupt header cannot make the reader allocate gigabytes
+	MaxBodies    = 1 << 26
+	MaxFrameData = 1 << 30
+)
+
+// Frame holds the state of all bodies at one step
+type Frame struct {
+	Step   int64
+	Time   float64
+	N      int       // Number of bodies
+	Fields []string  // Per body fields, in record order
+	Data   []float32 // N records of len(Fields) values
+}
+
+// return an empty frame with room for n bodies
+func NewFrame(fields []string, n int) *Frame {
+	return &Frame{N: n, Fields: fields, Data: make([]float32, n*len(fields))}
+}
+
+// Column returns the index of the named field, or -1
+func (f *Frame) Column(field string) int {
+	for i, name := range f.Fields {
+		if name == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// Value returns field c of body i
+func (f *Frame) Value(i, c int) float32 {
+	return f.Data[i*len(f.Fields)+c]
+}
+
+// Fill copies the state of the first numBodies bodies into the frame
+func (f *Frame) Fill(step int, time float32, bodies []*nbody.Body, numBodies int) {
+	if cap(f.Data) < numBodies*len(f.Fields) {
+		f.Data = make([]float32, numBodies*len(f.Fields))
+	}
+	f.Data = f.Data[:numBodies*len(f.Fields)]
+	f.Step, f.Time, f.N = int64(step), float64(time), numBodies
+
+	k := 0
+	for i := 0; i < numBodies; i++ {
+		for _, field := range f.Fields {
+			f.Data[k], _ = bodies[i].Value(field)
+			k++
+		}
+	}
+}
+
+// BodyFields drops the per frame columns (step, time) from a csv column
+// list, leaving the fields stored in every body record
+func BodyFields(columns []string) []string {
+	fields := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column != "step" && column != "time" {
+			fields = append(fields, column)
+		}
+	}
+	return fields
+}
+
+// Header is the decoded header of one frame
+type Header struct {
+	Version uint16
+	N       int
+	Step    int64
+	Time    float64
+	Fields  []string
+	Size    int // Header size in bytes, including padding
+}
+
+// FrameSize returns the number of bytes taken by the frame, header included
+func (h *Header) FrameSize() int64 {
+	return int64(h.Size) + 4*int64(h.N)*int64(len(h.Fields))
+}
+
+func headerSize(fields []string) int {
+	size := fixedHeaderSize
+	for _, field := range fields {
+		size += 1 + len(field)
+	}
+	return (size + 7) &^ 7
+}
+
+// Writer streams frames to an underlying writer
+type Writer struct {
+	w      *bufio.Writer
+	fields []string
+	frame  *Frame
+	buf    []byte
+}
+
+// return a new binary snapshot writer for the given per body fields
+func NewWriter(w io.Writer, fields []string) (*Writer, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("snapshot: no fields to write")
+	}
+	for _, field := range fields {
+		if len(field) > math.MaxUint8 {
+			return nil, fmt.Errorf("snapshot: field name %q too long", field)
+		}
+		if field == "step" || field == "time" {
+			return nil, fmt.Errorf("snapshot: %q is stored in the frame header", field)
+		}
+		if _, ok := (&nbody.Body{}).Value(field); !ok {
+			return nil, fmt.Errorf("snapshot: unknown field %q", field)
+		}
+	}
+	return &Writer{w: bufio.NewWriter(w), fields: fields, frame: NewFrame(fields, 0)}, nil
+}
+
+// write the state of the first numBodies bodies as one frame
+func (sw *Writer) WriteFrame(step int, time float32, bodies []*nbody.Body, numBodies int) error {
+	sw.frame.Fill(step, time, bodies, numBodies)
+	return sw.Write(sw.frame)
+}
+
+// Write writes one frame and flushes it to the underlying writer
+func (sw *Writer) Write(f *Frame) error {
+	if len(f.Fields) == 0 || len(f.Data) != f.N*len(f.Fields) {
+		return errors.New("snapshot: malformed frame")
+	}
+
+	size := headerSize(f.Fields)
+	header := make([]byte, size)
+	copy(header[0:4], Magic)
+	binary.LittleEndian.PutUint16(header[4:], Version)
+	binary.LittleEndian.PutUint16(header[6:], uint16(len(f.Fields)))
+	binary.LittleEndian.PutUint32(header[8:], uint32(f.N))
+	binary.LittleEndian.PutUint32(header[12:], uint32(size))
+	binary.LittleEndian.PutUint64(header[16:], uint64(f.Step))
+	binary.LittleEndian.PutUint64(header[24:], math.Float64bits(f.Time))
+	off := fixedHeaderSize
+	for _, field := range f.Fields {
+		header[off] = byte(len(field))
+		off += 1 + copy(header[off+1:], field)
+	}
+	if _, err := sw.w.Write(header); err != nil {
+		return err
+	}
+
+	if cap(sw.buf) < 4*len(f.Data) {
+		sw.buf = make([]byte, 4*len(f.Data))
+	}
+	buf := sw.buf[:4*len(f.Data)]
+	for i, v := range f.Data {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	if _, err := sw.w.Write(buf); err != nil {
+		return err
+	}
+	return sw.w.Flush()
+}
+
+// Reader reads frames written by Writer
+type Reader struct {
+	r         *bufio.Reader
+	buf       []byte
+	remaining int64 // Bytes left in the file, -1 if the size is unknown
+}
+
+// return a new binary snapshot reader
+func NewReader(r io.Reader) *Reader {
+	return newReader(bufio.NewReader(r), r)
+}
+
+// return a reader of the buffered src, the frames are checked against the
+// size of src if it can seek
+func newReader(r *bufio.Reader, src io.Reader) *Reader {
+	sr := &Reader{r: r, remaining: -1}
+	if seeker, ok := src.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return sr
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if _, back := seeker.Seek(start, io.SeekStart); err == nil && back == nil {
+			// BYTES ALREADY BUFFERED ARE STILL TO BE READ
+			sr.remaining = end - start + int64(r.Buffered())
+		}
+	}
+	return sr
+}
+
+// read exactly len(buf) bytes
+func (sr *Reader) read(buf []byte) error {
+	n, err := io.ReadFull(sr.r, buf)
+	if sr.remaining >= 0 {
+		sr.remaining -= int64(n)
+	}
+	return err
+}
+
+// ReadHeader reads the header of the next frame, leaving the reader
+// positioned at the start of the frame data. It returns io.EOF when there
+// are no more frames.
+func (sr *Reader) ReadHeader() (*Header, error) {
+	fixed := make([]byte, fixedHeaderSize)
+	if err := sr.read(fixed); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("snapshot: truncated frame header")
+		}
+		return nil, err
+	}
+	if string(fixed[0:4]) != Magic {
+		return nil, errors.New("snapshot: bad magic, not a snapshot file")
+	}
+
+	h := &Header{
+		Version: binary.LittleEndian.Uint16(fixed[4:]),
+		N:       int(binary.LittleEndian.Uint32(fixed[8:])),
+		Size:    int(binary.LittleEndian.Uint32(fixed[12:])),
+		Step:    int64(binary.LittleEndian.Uint64(fixed[16:])),
+		Time:    math.Float64frombits(binary.LittleEndian.Uint64(fixed[24:])),
+	}
+	if h.Version != Version {
+		return nil, fmt.Errorf("snapshot: unsupported version %d", h.Version)
+	}
+	// EVERY FIELD NAME TAKES AT MOST 1 + 255 BYTES
+	numFields := int(binary.LittleEndian.Uint16(fixed[6:]))
+	if numFields == 0 || h.Size < fixedHeaderSize || h.Size%8 != 0 ||
+		h.Size > fixedHeaderSize+numFields*(1+math.MaxUint8)+7 {
+		return nil, errors.New("snapshot: bad header size")
+	}
+	if h.N > MaxBodies || 4*int64(h.N)*int64(numFields) > MaxFrameData {
+		return nil, fmt.Errorf("snapshot: frame of %d bodies and %d fields is too large", h.N, numFields)
+	}
+	if sr.remaining >= 0 && int64(h.Size-fixedHeaderSize)+4*int64(h.N)*int64(numFields) > sr.remaining {
+		return nil, errors.New("snapshot: frame is larger than the rest of the file")
+	}
+
+	names := make([]byte, h.Size-fixedHeaderSize)
+	if err := sr.read(names); err != nil {
+		return nil, errors.New("snapshot: truncated frame header")
+	}
+	h.Fields = make([]string, numFields)
+	off := 0
+	for i := range h.Fields {
+		if off >= len(names) || off+1+int(names[off]) > len(names) {
+			return nil, errors.New("snapshot: bad field list")
+		}
+		h.Fields[i] = string(names[off+1 : off+1+int(names[off])])
+		off += 1 + int(names[off])
+	}
+	return h, nil
+}
+
+// Next reads the next frame, it returns io.EOF when there are no more frames
+func (sr *Reader) Next() (*Frame, error) {
+	h, err := sr.ReadHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	// WITHOUT THE SIZE OF THE FILE THE RECORDS ARE READ AS THEY COME, A
+	// TRUNCATED FRAME ONLY ALLOCATES WHAT IS THERE
+	size := 4 * h.N * len(h.Fields)
+	var buf []byte
+	if sr.remaining >= 0 {
+		if cap(sr.buf) < size {
+			sr.buf = make([]byte, size)
+		}
+		buf = sr.buf[:size]
+		if err := sr.read(buf); err != nil {
+			return nil, errors.New("snapshot: truncated frame data")
+		}
+	} else if buf, err = io.ReadAll(io.LimitReader(sr.r, int64(size))); err != nil || len(buf) < size {
+		return nil, errors.New("snapshot: truncated frame data")
+	}
+
+	f := NewFrame(h.Fields, h.N)
+	f.Step, f.Time = h.Step, h.Time
+	for i := range f.Data {
+		f.Data[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return f, nil
+}
+
+// FrameReader is implemented by Reader and CSVReader
+type FrameReader interface {
+	Next() (*Frame, error)
+}
+
+// FrameWriter is implemented by Writer and CSVWriter
+type FrameWriter interface {
+	Write(f *Frame) error
+}
+
+// NewFrameReader returns a Reader if r starts with a binary frame and a
+// CSVReader otherwise
+func NewFrameReader(r io.Reader) FrameReader {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(len(Magic)); err == nil && string(magic) == Magic {
+		return newReader(br, r)
+	}
+	return NewCSVReader(br)
+}
+
+// Convert copies every frame from src to dst and returns the number of
+// frames copied
+func Convert(dst FrameWriter, src FrameReader) (int, error) {
+	frames := 0
+	for {
+		f, err := src.Next()
+		if err == io.EOF {
+			return frames, nil
+		} else if err != nil {
+			return frames, err
+		}
+		if err := dst.Write(f); err != nil {
+			return frames, err
+		}
+		frames++
+	}
+}
+
+// ReadAll reads every remaining frame from src
+func ReadAll(src FrameReader) ([]*Frame, error) {
+	var frames []*Frame
+	for {
+		f, err := src.Next()
+		if err == io.EOF {
+			return frames, nil
+		} else if err != nil {
+			return frames, err
+		}
+		frames = append(frames, f)
+	}
+}
@@ -0,0 +1,142 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"proj3/nbody"
+)
+
+// return three bodies with distinct values in every field
+func testBodies() []*nbody.Body {
+	return []*nbody.Body{
+		nbody.NewBodyFromState(nbody.BodyState{ID: 1, X: 1, Y: 2, Z: 3, VX: 0.5, Mass: 2}),
+		nbody.NewBodyFromState(nbody.BodyState{ID: 4, X: -1, Y: 0.25, VY: -2, Mass: 1}),
+		nbody.NewBodyFromState(nbody.BodyState{ID: 6, Z: -8, VZ: 1.5, Mass: 0.125}),
+	}
+}
+
+// return a file of two binary frames of the test bodies
+func binaryFile(t *testing.T, fields []string) []byte {
+	var out bytes.Buffer
+	sw, err := NewWriter(&out, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := testBodies()
+	for step := 0; step < 2; step++ {
+		if err := sw.WriteFrame(10*step, float32(step)/4, bodies, len(bodies)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out.Bytes()
+}
+
+// the frames read back hold every field of every body written
+func checkFrames(t *testing.T, frames []*Frame, fields []string) {
+	t.Helper()
+	bodies := testBodies()
+	if len(frames) != 2 {
+		t.Fatalf("read %d frames, want 2", len(frames))
+	}
+	for step, f := range frames {
+		if f.Step != int64(10*step) || f.Time != float64(step)/4 || f.N != len(bodies) {
+			t.Fatalf("frame %d: step %d time %g with %d bodies", step, f.Step, f.Time, f.N)
+		}
+		if !reflect.DeepEqual(f.Fields, fields) {
+			t.Fatalf("frame %d: fields %v, want %v", step, f.Fields, fields)
+		}
+		for i, body := range bodies {
+			for c, field := range fields {
+				if want, _ := body.Value(field); f.Value(i, c) != want {
+					t.Fatalf("frame %d: %s of body %d is %g, want %g", step, field, i, f.Value(i, c), want)
+				}
+			}
+		}
+	}
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	fields := []string{"id", "x", "y", "z", "vx", "vy", "vz", "mass"}
+	file := binaryFile(t, fields)
+
+	frames, err := ReadAll(NewReader(bytes.NewReader(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFrames(t, frames, fields)
+
+	// WITHOUT A SEEKER THE SIZE OF THE FILE IS UNKNOWN
+	frames, err = ReadAll(NewFrameReader(io.MultiReader(bytes.NewReader(file))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFrames(t, frames, fields)
+}
+
+func TestConvert(t *testing.T) {
+	fields := []string{"id", "x", "vz", "mass"}
+	file := binaryFile(t, fields)
+
+	var csv bytes.Buffer
+	if n, err := Convert(NewCSVWriter(&csv, 6), NewFrameReader(bytes.NewReader(file))); err != nil || n != 2 {
+		t.Fatalf("binary to csv converted %d frames: %v", n, err)
+	}
+	if header := strings.SplitN(csv.String(), "\n", 2)[0]; header != "step, time, id, x, vz, mass" {
+		t.Fatalf("csv header %q", header)
+	}
+
+	var binary bytes.Buffer
+	sw, err := NewWriter(&binary, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := Convert(sw, NewFrameReader(&csv)); err != nil || n != 2 {
+		t.Fatalf("csv to binary converted %d frames: %v", n, err)
+	}
+	if !bytes.Equal(binary.Bytes(), file) {
+		t.Fatal("the binary file converted to csv and back differs")
+	}
+}
+
+// a corrupt header is refused before the frame is allocated
+func TestReaderCorruptHeader(t *testing.T) {
+	file := binaryFile(t, []string{"id", "x"})
+	frame := len(file) / 2
+	corrupt := func(offset int, value uint32) []byte {
+		bad := append([]byte(nil), file[:frame]...)
+		binary.LittleEndian.PutUint32(bad[offset:], value)
+		return bad
+	}
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"truncated header", file[:20]},
+		{"truncated data", file[:frame-1]},
+		{"bad magic", append([]byte("NBSX"), file[4:frame]...)},
+		{"no fields", corrupt(4, 1)},
+		{"header too small", corrupt(12, 8)},
+		{"header too large", corrupt(12, 1<<31)},
+		{"too many bodies", corrupt(8, MaxBodies+1)},
+		{"more bodies than the file", corrupt(8, 1<<20)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			readers := map[string]io.Reader{
+				"seeker": bytes.NewReader(test.file),
+				"stream": io.MultiReader(bytes.NewReader(test.file)),
+			}
+			for name, r := range readers {
+				if _, err := NewReader(r).Next(); err == nil || err == io.EOF {
+					t.Errorf("%s: read the frame, err %v", name, err)
+				}
+			}
+		})
+	}
+}